@@ -6,10 +6,12 @@ import (
 	"log"
 	"net"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/anacrolix/torrent"
@@ -27,11 +29,18 @@ type args struct {
 	LAddr       string `arg:"-l" help:"web server listen addr (can be unix:<path to socket>)" default:"localhost:8090"`
 	Path        string `arg:"-d" help:"database and config dir path"`
 	RDB         bool   `arg:"-r" help:"start in read-only DB mode"`
+	HttpAuth    bool   `arg:"-a" help:"enable http auth on all requests, accounts are read from accs.db"`
+	Ssl         bool   `help:"enable https listener"`
+	SslAddr     string `help:"https listen addr" default:"localhost:8091"`
+	SslCert     string `help:"path to certificate, def <path>/server.crt, self-signed is generated if cert and key not exist"`
+	SslKey      string `help:"path to certificate key, def <path>/server.key"`
 	TorrentsDir string `arg:"-t" help:"autoload torrents from dir"`
 	TorrentAddr string `help:"Torrent client address, like 127.0.0.1:1337 (default :PeersListenPort)"`
 	PubIPv4     string `arg:"-4" help:"set public IPv4 addr"`
 	PubIPv6     string `arg:"-6" help:"set public IPv6 addr"`
 	MaxSize     string `arg:"-m" help:"max allowed stream size (in Bytes)"`
+	Debug       bool   `help:"enable /debug endpoints: pprof, client status, piece maps and readers, need http auth or local client"`
+	DlnaAddr    string `help:"dlna media server listen addr, server is enabled by EnableDLNA setting" default:":9080"`
 }
 
 func (args) Version() string {
@@ -40,6 +49,9 @@ func (args) Version() string {
 
 var params args
 
+// systemd unit waits 30 seconds before kill
+const shutdownTimeout = 20 * time.Second
+
 func main() {
 	runtime.GOMAXPROCS(runtime.NumCPU())
 
@@ -50,6 +62,13 @@ func main() {
 	}
 
 	settings.Path = params.Path
+	settings.HttpAuth = params.HttpAuth
+	settings.Ssl = params.Ssl
+	settings.SslAddr = params.SslAddr
+	settings.SslCert = params.SslCert
+	settings.SslKey = params.SslKey
+	settings.Debug = params.Debug
+	settings.DlnaAddr = params.DlnaAddr
 	fmt.Println("=========== START ===========")
 	fmt.Println("TorrServer", version.Version+",", runtime.Version()+",", "CPU Num:", runtime.NumCPU())
 
@@ -83,8 +102,32 @@ func main() {
 	}
 
 	server.Start(params.LAddr, params.RDB)
-	log.Println(server.WaitServer())
-	time.Sleep(time.Second * 3)
+
+	waitServer := make(chan string, 1)
+	go func() {
+		waitServer <- server.WaitServer()
+	}()
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	for stop := false; !stop; {
+		select {
+		case s := <-sig:
+			if s == syscall.SIGHUP {
+				log.Println("Reload settings")
+				server.Reload()
+				continue
+			}
+			log.Println("Received signal", s)
+			stop = true
+		case <-torr.ShutdownRequested():
+			stop = true
+		case err := <-waitServer:
+			log.Println(err)
+			stop = true
+		}
+	}
+	server.Stop(shutdownTimeout)
+	log.Println("Quit")
 	os.Exit(0)
 }
 
@@ -0,0 +1,346 @@
+package dlna
+
+import (
+	"encoding/xml"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"server/mimetype"
+	"server/settings"
+	"server/torr"
+	"server/torr/state"
+	"server/web/auth"
+)
+
+// Object ids: "0" root, "c:<category>" category, "t:<hash>" torrent, "f:<hash>:<file id>" file.
+// Torrents without category are in root.
+const (
+	rootID         = "0"
+	categoryPrefix = "c:"
+	torrentPrefix  = "t:"
+	filePrefix     = "f:"
+)
+
+type didlLite struct {
+	XMLName    xml.Name     `xml:"DIDL-Lite"`
+	Xmlns      string       `xml:"xmlns,attr"`
+	XmlnsDC    string       `xml:"xmlns:dc,attr"`
+	XmlnsUPnP  string       `xml:"xmlns:upnp,attr"`
+	XmlnsDLNA  string       `xml:"xmlns:dlna,attr"`
+	Containers []didlObject `xml:"container"`
+	Items      []didlObject `xml:"item"`
+}
+
+type didlObject struct {
+	ID         string    `xml:"id,attr"`
+	ParentID   string    `xml:"parentID,attr"`
+	Restricted int       `xml:"restricted,attr"`
+	Searchable *int      `xml:"searchable,attr,omitempty"`
+	ChildCount *int      `xml:"childCount,attr,omitempty"`
+	Title      string    `xml:"dc:title"`
+	Class      string    `xml:"upnp:class"`
+	AlbumArt   string    `xml:"upnp:albumArtURI,omitempty"`
+	Res        []didlRes `xml:"res,omitempty"`
+
+	isItem bool
+}
+
+type didlRes struct {
+	ProtocolInfo string `xml:"protocolInfo,attr"`
+	Size         int64  `xml:"size,attr,omitempty"`
+	URL          string `xml:",chardata"`
+}
+
+func (s *server) contentDirectory(w http.ResponseWriter, r *http.Request) {
+	action, args, err := readSOAP(r)
+	if err != nil {
+		writeSOAPFault(w, err)
+		return
+	}
+	logger.Debug("content directory", "action", action, "args", args, "remote", r.RemoteAddr, "agent", r.UserAgent())
+	switch action {
+	case "Browse":
+		s.browse(w, r, args)
+	case "GetSearchCapabilities":
+		writeSOAP(w, contentDirectoryType, action, [][2]string{{"SearchCaps", ""}})
+	case "GetSortCapabilities":
+		writeSOAP(w, contentDirectoryType, action, [][2]string{{"SortCaps", ""}})
+	case "GetSystemUpdateID":
+		writeSOAP(w, contentDirectoryType, action, [][2]string{{"Id", strconv.FormatUint(uint64(updateID(torr.ListTorrent())), 10)}})
+	default:
+		writeSOAPFault(w, &soapError{errInvalidAction, "Invalid Action"})
+	}
+}
+
+func (s *server) browse(w http.ResponseWriter, r *http.Request, args map[string]string) {
+	id := args["ObjectID"]
+	start, _ := strconv.Atoi(args["StartingIndex"])
+	count, _ := strconv.Atoi(args["RequestedCount"])
+	list := torr.ListTorrent()
+	host := "http://" + r.Host
+
+	var objs []didlObject
+	var err error
+	switch args["BrowseFlag"] {
+	case "BrowseMetadata":
+		var obj didlObject
+		obj, err = metadata(list, id, host)
+		objs = []didlObject{obj}
+	case "BrowseDirectChildren":
+		objs, err = children(list, id, host)
+	default:
+		err = &soapError{errInvalidArgs, "Invalid Args"}
+	}
+	if err != nil {
+		writeSOAPFault(w, err)
+		return
+	}
+
+	total := len(objs)
+	start = min(max(start, 0), total)
+	end := total
+	if count > 0 {
+		end = min(start+count, total)
+	}
+	objs = objs[start:end]
+
+	didl := didlLite{
+		Xmlns:     "urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/",
+		XmlnsDC:   "http://purl.org/dc/elements/1.1/",
+		XmlnsUPnP: "urn:schemas-upnp-org:metadata-1-0/upnp/",
+		XmlnsDLNA: "urn:schemas-dlna-org:metadata-1-0/",
+	}
+	for _, obj := range objs {
+		if obj.isItem {
+			didl.Items = append(didl.Items, obj)
+		} else {
+			didl.Containers = append(didl.Containers, obj)
+		}
+	}
+	buf, err := xml.Marshal(didl)
+	if err != nil {
+		writeSOAPFault(w, err)
+		return
+	}
+	writeSOAP(w, contentDirectoryType, "Browse", [][2]string{
+		{"Result", string(buf)},
+		{"NumberReturned", strconv.Itoa(len(objs))},
+		{"TotalMatches", strconv.Itoa(total)},
+		{"UpdateID", strconv.FormatUint(uint64(updateID(list)), 10)},
+	})
+}
+
+// updateID changes when torrents are added, removed or edited, renderers refresh cached lists by it
+func updateID(list []*torr.Torrent) uint32 {
+	h := fnv.New32a()
+	for _, t := range list {
+		fmt.Fprintf(h, "%s/%s/%s/%d\n", t.Hash().HexString(), t.Title, t.Category, len(t.Data))
+	}
+	return h.Sum32()
+}
+
+func metadata(list []*torr.Torrent, id, host string) (didlObject, error) {
+	switch {
+	case id == rootID:
+		return container(rootID, "-1", friendlyName(), len(rootChildren(list))), nil
+	case strings.HasPrefix(id, categoryPrefix):
+		cat, err := url.PathUnescape(strings.TrimPrefix(id, categoryPrefix))
+		if err != nil {
+			break
+		}
+		if n := len(categoryTorrents(list, cat)); n > 0 {
+			return container(id, rootID, cat, n), nil
+		}
+	case strings.HasPrefix(id, torrentPrefix):
+		if t := findTorrent(list, strings.TrimPrefix(id, torrentPrefix)); t != nil {
+			return torrentContainer(t), nil
+		}
+	case strings.HasPrefix(id, filePrefix):
+		hash, _, ok := strings.Cut(strings.TrimPrefix(id, filePrefix), ":")
+		if t := findTorrent(list, hash); ok && t != nil {
+			for _, item := range fileItems(t, t.FileStats(), host) {
+				if item.ID == id {
+					return item, nil
+				}
+			}
+		}
+	}
+	return didlObject{}, &soapError{errNoSuchObject, "No such object"}
+}
+
+func children(list []*torr.Torrent, id, host string) ([]didlObject, error) {
+	switch {
+	case id == rootID:
+		return rootChildren(list), nil
+	case strings.HasPrefix(id, categoryPrefix):
+		cat, err := url.PathUnescape(strings.TrimPrefix(id, categoryPrefix))
+		if err != nil {
+			break
+		}
+		var ret []didlObject
+		for _, t := range categoryTorrents(list, cat) {
+			ret = append(ret, torrentContainer(t))
+		}
+		return ret, nil
+	case strings.HasPrefix(id, torrentPrefix):
+		t := findTorrent(list, strings.TrimPrefix(id, torrentPrefix))
+		if t == nil {
+			break
+		}
+		return fileItems(t, torrentFiles(t), host), nil
+	}
+	return nil, &soapError{errNoSuchObject, "No such object"}
+}
+
+// rootChildren returns category containers and torrents without category
+func rootChildren(list []*torr.Torrent) []didlObject {
+	counts := make(map[string]int)
+	var cats []string
+	var ret []didlObject
+	for _, t := range list {
+		if t.Category == "" {
+			continue
+		}
+		if counts[t.Category] == 0 {
+			cats = append(cats, t.Category)
+		}
+		counts[t.Category]++
+	}
+	sort.Strings(cats)
+	for _, cat := range cats {
+		ret = append(ret, container(categoryPrefix+url.PathEscape(cat), rootID, cat, counts[cat]))
+	}
+	for _, t := range categoryTorrents(list, "") {
+		ret = append(ret, torrentContainer(t))
+	}
+	return ret
+}
+
+func categoryTorrents(list []*torr.Torrent, cat string) []*torr.Torrent {
+	var ret []*torr.Torrent
+	for _, t := range list {
+		if t.Category == cat {
+			ret = append(ret, t)
+		}
+	}
+	return ret
+}
+
+func findTorrent(list []*torr.Torrent, hash string) *torr.Torrent {
+	for _, t := range list {
+		if t.Hash().HexString() == hash {
+			return t
+		}
+	}
+	return nil
+}
+
+func container(id, parentID, title string, childCount int) didlObject {
+	return didlObject{
+		ID:         id,
+		ParentID:   parentID,
+		Restricted: 1,
+		ChildCount: &childCount,
+		Title:      title,
+		Class:      "object.container.storageFolder",
+	}
+}
+
+func torrentContainer(t *torr.Torrent) didlObject {
+	parentID := rootID
+	if t.Category != "" {
+		parentID = categoryPrefix + url.PathEscape(t.Category)
+	}
+	title := t.Title
+	if title == "" {
+		title = t.Status().Name
+	}
+	if title == "" {
+		title = t.Hash().HexString()
+	}
+	obj := didlObject{
+		ID:         torrentPrefix + t.Hash().HexString(),
+		ParentID:   parentID,
+		Restricted: 1,
+		Title:      title,
+		Class:      "object.container.storageFolder",
+		AlbumArt:   t.Poster,
+	}
+	// files of torrent not got info and without saved files are unknown until browse
+	if files := t.FileStats(); len(files) > 0 {
+		n := 0
+		for _, f := range files {
+			if mimetype.MimeTypeByPath(f.Path).IsMedia() {
+				n++
+			}
+		}
+		obj.ChildCount = &n
+	}
+	return obj
+}
+
+// torrentFiles returns known files of torrent, torrent is started and its info is waited otherwise
+func torrentFiles(t *torr.Torrent) []*state.TorrentFileStat {
+	if files := t.FileStats(); len(files) > 0 {
+		return files
+	}
+	if t.Stat == state.TorrentInDB {
+		tor, err := torr.AddTorrent(t.TorrentSpec, t.Title, t.Poster, t.Data, t.Category)
+		if err != nil {
+			logger.Error("start torrent", "hash", t.Hash().HexString(), "err", err)
+			return nil
+		}
+		t = tor
+	}
+	if !t.GotInfo() {
+		return nil
+	}
+	return t.FileStats()
+}
+
+func fileItems(t *torr.Torrent, files []*state.TorrentFileStat, host string) []didlObject {
+	hash := t.Hash().HexString()
+	var ret []didlObject
+	for _, f := range files {
+		mime := mimetype.MimeTypeByPath(f.Path)
+		if !mime.IsMedia() {
+			continue
+		}
+		class := "object.item.videoItem"
+		switch {
+		case mime.IsAudio():
+			class = "object.item.audioItem.musicTrack"
+		case mime.IsImage():
+			class = "object.item.imageItem.photo"
+		}
+		ret = append(ret, didlObject{
+			ID:         filePrefix + hash + ":" + strconv.Itoa(f.Id),
+			ParentID:   torrentPrefix + hash,
+			Restricted: 1,
+			Title:      path.Base(f.Path),
+			Class:      class,
+			Res: []didlRes{{
+				ProtocolInfo: "http-get:*:" + mime.String() + ":" + mime.ContentFeatures(),
+				Size:         f.Length,
+				URL:          playURL(host, hash, strconv.Itoa(f.Id)),
+			}},
+			isItem: true,
+		})
+	}
+	return ret
+}
+
+// playURL returns play link of file, signed if signed links are enabled
+func playURL(host, hash, index string) string {
+	link := host + "/play/" + hash + "/" + index
+	if settings.BTsets != nil && settings.BTsets.SignLinks {
+		link += "?" + auth.SignLink(hash, index)
+	}
+	return link
+}
@@ -0,0 +1,137 @@
+package dlna
+
+import (
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+
+	"server/logs"
+	"server/settings"
+)
+
+var logger = logs.For("dlna")
+
+var (
+	srv         *server
+	mu          sync.Mutex
+	playHandler http.Handler
+)
+
+// server is upnp media server: ssdp and http of device description, content directory and play links
+type server struct {
+	uuid string
+	name string
+	port int
+
+	http *http.Server
+	ssdp *ssdpServer
+}
+
+// SetPlayHandler sets handler of /play/ links of media server resources
+func SetPlayHandler(h http.Handler) {
+	mu.Lock()
+	playHandler = h
+	mu.Unlock()
+}
+
+// Apply starts or stops media server by EnableDLNA setting, running server is restarted on name change
+func Apply() {
+	mu.Lock()
+	defer mu.Unlock()
+	if srv != nil && (!settings.BTsets.EnableDLNA || srv.name != friendlyName()) {
+		srv.stop()
+		srv = nil
+	}
+	if srv == nil && settings.BTsets.EnableDLNA {
+		s, err := start()
+		if err != nil {
+			logger.Error("start media server", "addr", settings.DlnaAddr, "err", err)
+			return
+		}
+		srv = s
+	}
+}
+
+// Stop sends byebye and stops media server
+func Stop() {
+	mu.Lock()
+	defer mu.Unlock()
+	if srv != nil {
+		srv.stop()
+		srv = nil
+	}
+}
+
+func start() (*server, error) {
+	l, err := net.Listen("tcp4", settings.DlnaAddr)
+	if err != nil {
+		return nil, err
+	}
+	s := &server{
+		uuid: deviceUUID(),
+		name: friendlyName(),
+		port: l.Addr().(*net.TCPAddr).Port,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /dlna/rootDesc.xml", s.rootDesc)
+	mux.HandleFunc("GET /dlna/ContentDirectory.xml", serveXML(contentDirectorySCPD))
+	mux.HandleFunc("GET /dlna/ConnectionManager.xml", serveXML(connectionManagerSCPD))
+	mux.HandleFunc("POST /dlna/control/ContentDirectory", s.contentDirectory)
+	mux.HandleFunc("POST /dlna/control/ConnectionManager", s.connectionManager)
+	mux.HandleFunc("/dlna/event/", s.event)
+	if playHandler != nil {
+		mux.Handle("/play/", playHandler)
+	}
+	s.http = &http.Server{Handler: mux}
+	go func() {
+		if err := s.http.Serve(l); !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("media server", "err", err)
+		}
+	}()
+
+	s.ssdp, err = startSSDP(s)
+	if err != nil {
+		s.http.Close()
+		return nil, err
+	}
+	logger.Info("start media server", "name", s.name, "addr", l.Addr().String())
+	return s, nil
+}
+
+func (s *server) stop() {
+	s.ssdp.stop()
+	s.http.Close()
+	logger.Info("stop media server")
+}
+
+func friendlyName() string {
+	if settings.BTsets.FriendlyName != "" {
+		return settings.BTsets.FriendlyName
+	}
+	host, _ := os.Hostname()
+	return "TorrServer on " + host
+}
+
+// deviceUUID is stable for host and config dir, renderers remember server by it
+func deviceUUID() string {
+	host, _ := os.Hostname()
+	h := md5.Sum([]byte("TorrServer/" + host + "/" + settings.Path))
+	return fmt.Sprintf("%x-%x-%x-%x-%x", h[0:4], h[4:6], h[6:8], h[8:10], h[10:16])
+}
+
+// event accepts subscriptions to services events, state variables are not evented
+func (s *server) event(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "SUBSCRIBE":
+		w.Header()["SID"] = []string{"uuid:" + s.uuid}
+		w.Header()["TIMEOUT"] = []string{"Second-1800"}
+	case "UNSUBSCRIBE":
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
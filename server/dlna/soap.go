@@ -0,0 +1,124 @@
+package dlna
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// upnp control errors
+const (
+	errInvalidAction = 401
+	errInvalidArgs   = 402
+	errActionFailed  = 501
+	errNoSuchObject  = 701
+)
+
+type soapError struct {
+	code int
+	desc string
+}
+
+func (e *soapError) Error() string {
+	return fmt.Sprintf("upnp error %d: %s", e.code, e.desc)
+}
+
+// readSOAP returns action name of SOAPACTION header and arguments of action element
+func readSOAP(r *http.Request) (string, map[string]string, error) {
+	soapAction := strings.Trim(r.Header.Get("SOAPACTION"), `"`)
+	_, action, ok := strings.Cut(soapAction, "#")
+	if !ok {
+		return "", nil, &soapError{errInvalidAction, "Invalid Action"}
+	}
+	args := make(map[string]string)
+	dec := xml.NewDecoder(io.LimitReader(r.Body, 64<<10))
+	depth, bodyDepth := 0, 0
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", nil, &soapError{errInvalidArgs, "Invalid Args"}
+		}
+		switch se := tok.(type) {
+		case xml.StartElement:
+			depth++
+			if bodyDepth == 0 && se.Name.Local == "Body" {
+				bodyDepth = depth
+			}
+			// Body > Action > Argument
+			if bodyDepth > 0 && depth == bodyDepth+2 {
+				var val string
+				if err := dec.DecodeElement(&val, &se); err != nil {
+					return "", nil, &soapError{errInvalidArgs, "Invalid Args"}
+				}
+				args[se.Name.Local] = val
+				depth--
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+	return action, args, nil
+}
+
+// writeSOAP writes action response with out arguments in given order
+func writeSOAP(w http.ResponseWriter, service, action string, args [][2]string) {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="utf-8"?>` + "\n")
+	b.WriteString(`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body>`)
+	fmt.Fprintf(&b, `<u:%sResponse xmlns:u="%s">`, action, service)
+	for _, arg := range args {
+		fmt.Fprintf(&b, "<%s>%s</%s>", arg[0], xmlEscape(arg[1]), arg[0])
+	}
+	fmt.Fprintf(&b, `</u:%sResponse></s:Body></s:Envelope>`, action)
+	w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+	w.Header()["EXT"] = []string{""}
+	w.Header().Set("Server", serverHeader())
+	io.WriteString(w, b.String())
+}
+
+func writeSOAPFault(w http.ResponseWriter, err error) {
+	se, ok := err.(*soapError)
+	if !ok {
+		se = &soapError{errActionFailed, err.Error()}
+	}
+	w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+	w.WriteHeader(http.StatusInternalServerError)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body><s:Fault><faultcode>s:Client</faultcode><faultstring>UPnPError</faultstring><detail><UPnPError xmlns="urn:schemas-upnp-org:control-1-0"><errorCode>%d</errorCode><errorDescription>%s</errorDescription></UPnPError></detail></s:Fault></s:Body></s:Envelope>`,
+		se.code, xmlEscape(se.desc))
+}
+
+// connectionManager answers ConnectionManager actions, only http-get of media is served
+func (s *server) connectionManager(w http.ResponseWriter, r *http.Request) {
+	action, _, err := readSOAP(r)
+	if err != nil {
+		writeSOAPFault(w, err)
+		return
+	}
+	switch action {
+	case "GetProtocolInfo":
+		writeSOAP(w, connectionManagerType, action, [][2]string{
+			{"Source", "http-get:*:video/*:*,http-get:*:audio/*:*,http-get:*:image/*:*"},
+			{"Sink", ""},
+		})
+	case "GetCurrentConnectionIDs":
+		writeSOAP(w, connectionManagerType, action, [][2]string{{"ConnectionIDs", "0"}})
+	case "GetCurrentConnectionInfo":
+		writeSOAP(w, connectionManagerType, action, [][2]string{
+			{"RcsID", "-1"},
+			{"AVTransportID", "-1"},
+			{"ProtocolInfo", ""},
+			{"PeerConnectionManager", ""},
+			{"PeerConnectionID", "-1"},
+			{"Direction", "Output"},
+			{"Status", "OK"},
+		})
+	default:
+		writeSOAPFault(w, &soapError{errInvalidAction, "Invalid Action"})
+	}
+}
@@ -0,0 +1,287 @@
+package dlna
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/ipv4"
+
+	"server/settings"
+	"server/version"
+)
+
+const (
+	ssdpAddr   = "239.255.255.250:1900"
+	ssdpMaxAge = 1800
+	// alive is repeated well before max age ends
+	ssdpNotifyInterval = 10 * time.Minute
+)
+
+var ssdpGroup = &net.UDPAddr{IP: net.IPv4(239, 255, 255, 250), Port: 1900}
+
+// ssdpServer announces media server and answers searches on lan interfaces
+type ssdpServer struct {
+	srv    *server
+	pc     *ipv4.PacketConn
+	ifaces []ssdpIface
+	done   chan struct{}
+	wg     sync.WaitGroup
+	muSend sync.Mutex
+}
+
+type ssdpIface struct {
+	ifi   net.Interface
+	ip    net.IP
+	ipNet *net.IPNet
+}
+
+func startSSDP(srv *server) (*ssdpServer, error) {
+	ifaces, err := ssdpInterfaces()
+	if err != nil {
+		return nil, err
+	}
+	if len(ifaces) == 0 {
+		return nil, fmt.Errorf("no multicast interfaces with ipv4 address")
+	}
+	// listen on group address binds any address with reuse, other ssdp services keep working
+	c, err := net.ListenPacket("udp4", ssdpAddr)
+	if err != nil {
+		return nil, err
+	}
+	s := &ssdpServer{
+		srv:  srv,
+		pc:   ipv4.NewPacketConn(c),
+		done: make(chan struct{}),
+	}
+	for _, iface := range ifaces {
+		if err := s.pc.JoinGroup(&iface.ifi, ssdpGroup); err != nil {
+			logger.Warn("ssdp join group", "iface", iface.ifi.Name, "err", err)
+			continue
+		}
+		s.ifaces = append(s.ifaces, iface)
+	}
+	if len(s.ifaces) == 0 {
+		c.Close()
+		return nil, fmt.Errorf("ssdp join group failed on all interfaces")
+	}
+	// incoming interface selects location address of response
+	s.pc.SetControlMessage(ipv4.FlagInterface, true)
+	s.pc.SetMulticastTTL(2)
+
+	s.wg.Add(2)
+	go s.serve()
+	go s.announce()
+	return s, nil
+}
+
+// ssdpInterfaces returns up multicast interfaces with ipv4, only interface of listen host if it is set
+func ssdpInterfaces() ([]ssdpIface, error) {
+	host, _, _ := net.SplitHostPort(settings.DlnaAddr)
+	listenIP := net.ParseIP(host)
+	if listenIP != nil && listenIP.IsUnspecified() {
+		listenIP = nil
+	}
+	list, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	var ret []ssdpIface
+	for _, ifi := range list {
+		if ifi.Flags&net.FlagUp == 0 || ifi.Flags&net.FlagMulticast == 0 || ifi.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := ifi.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok || ipNet.IP.To4() == nil {
+				continue
+			}
+			if listenIP != nil && !listenIP.Equal(ipNet.IP) {
+				continue
+			}
+			ret = append(ret, ssdpIface{ifi: ifi, ip: ipNet.IP.To4(), ipNet: ipNet})
+			break
+		}
+	}
+	return ret, nil
+}
+
+func (s *ssdpServer) stop() {
+	close(s.done)
+	for _, iface := range s.ifaces {
+		s.notify(iface, "ssdp:byebye")
+	}
+	s.pc.Close()
+	s.wg.Wait()
+}
+
+func (s *ssdpServer) announce() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(ssdpNotifyInterval)
+	defer ticker.Stop()
+	for {
+		for _, iface := range s.ifaces {
+			s.notify(iface, "ssdp:alive")
+		}
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *ssdpServer) serve() {
+	defer s.wg.Done()
+	buf := make([]byte, 4096)
+	for {
+		n, cm, src, err := s.pc.ReadFrom(buf)
+		if err != nil {
+			select {
+			case <-s.done:
+			default:
+				logger.Error("ssdp read", "err", err)
+			}
+			return
+		}
+		addr, ok := src.(*net.UDPAddr)
+		if !ok {
+			continue
+		}
+		s.handle(buf[:n], cm, addr)
+	}
+}
+
+// handle answers M-SEARCH requests for our targets
+func (s *ssdpServer) handle(msg []byte, cm *ipv4.ControlMessage, src *net.UDPAddr) {
+	req, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(msg)))
+	if err != nil || req.Method != "M-SEARCH" || req.Header.Get("Man") != `"ssdp:discover"` {
+		return
+	}
+	st := req.Header.Get("St")
+	var targets []string
+	for _, t := range s.targets() {
+		if st == "ssdp:all" || st == t {
+			targets = append(targets, t)
+		}
+	}
+	if len(targets) == 0 {
+		return
+	}
+	iface, ok := s.iface(cm, src.IP)
+	if !ok {
+		return
+	}
+	mx, _ := strconv.Atoi(req.Header.Get("Mx"))
+	mx = min(max(mx, 1), 3)
+	delay := rand.N(time.Duration(mx) * time.Second)
+	logger.Debug("ssdp search", "remote", src.String(), "st", st, "agent", req.Header.Get("User-Agent"))
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		select {
+		case <-s.done:
+			return
+		case <-time.After(delay):
+		}
+		for _, t := range targets {
+			resp := "HTTP/1.1 200 OK\r\n" +
+				"CACHE-CONTROL: max-age=" + strconv.Itoa(ssdpMaxAge) + "\r\n" +
+				"DATE: " + time.Now().UTC().Format(http.TimeFormat) + "\r\n" +
+				"EXT:\r\n" +
+				"LOCATION: " + s.location(iface) + "\r\n" +
+				"SERVER: " + serverHeader() + "\r\n" +
+				"ST: " + t + "\r\n" +
+				"USN: " + s.usn(t) + "\r\n" +
+				"\r\n"
+			s.send(resp, nil, src)
+		}
+	}()
+}
+
+// iface finds interface of request by control message or by sender subnet
+func (s *ssdpServer) iface(cm *ipv4.ControlMessage, ip net.IP) (ssdpIface, bool) {
+	if cm != nil {
+		for _, iface := range s.ifaces {
+			if iface.ifi.Index == cm.IfIndex {
+				return iface, true
+			}
+		}
+	}
+	for _, iface := range s.ifaces {
+		if iface.ipNet.Contains(ip) {
+			return iface, true
+		}
+	}
+	return ssdpIface{}, false
+}
+
+func (s *ssdpServer) notify(iface ssdpIface, nts string) {
+	for _, t := range s.targets() {
+		msg := "NOTIFY * HTTP/1.1\r\n" +
+			"HOST: " + ssdpAddr + "\r\n" +
+			"NT: " + t + "\r\n" +
+			"NTS: " + nts + "\r\n" +
+			"USN: " + s.usn(t) + "\r\n"
+		if nts == "ssdp:alive" {
+			msg += "CACHE-CONTROL: max-age=" + strconv.Itoa(ssdpMaxAge) + "\r\n" +
+				"LOCATION: " + s.location(iface) + "\r\n" +
+				"SERVER: " + serverHeader() + "\r\n"
+		}
+		msg += "\r\n"
+		s.send(msg, &iface.ifi, ssdpGroup)
+	}
+}
+
+// send writes message to dst, multicast goes out of ifi
+func (s *ssdpServer) send(msg string, ifi *net.Interface, dst *net.UDPAddr) {
+	s.muSend.Lock()
+	defer s.muSend.Unlock()
+	if ifi != nil {
+		if err := s.pc.SetMulticastInterface(ifi); err != nil {
+			logger.Debug("ssdp set multicast interface", "iface", ifi.Name, "err", err)
+			return
+		}
+	}
+	if _, err := s.pc.WriteTo([]byte(msg), nil, dst); err != nil {
+		logger.Debug("ssdp send", "dst", dst.String(), "err", err)
+	}
+}
+
+func (s *ssdpServer) targets() []string {
+	return []string{
+		"upnp:rootdevice",
+		"uuid:" + s.srv.uuid,
+		deviceType,
+		contentDirectoryType,
+		connectionManagerType,
+	}
+}
+
+func (s *ssdpServer) usn(target string) string {
+	if strings.HasPrefix(target, "uuid:") {
+		return target
+	}
+	return "uuid:" + s.srv.uuid + "::" + target
+}
+
+func (s *ssdpServer) location(iface ssdpIface) string {
+	return fmt.Sprintf("http://%s/dlna/rootDesc.xml", net.JoinHostPort(iface.ip.String(), strconv.Itoa(s.srv.port)))
+}
+
+func serverHeader() string {
+	return runtime.GOOS + "/1.0 UPnP/1.0 TorrServer/" + version.Version
+}
@@ -0,0 +1,179 @@
+package logs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Subsystems with own log level
+var Subsystems = []string{"main", "settings", "torr", "torrstor", "web", "dlna", "search"}
+
+var (
+	levels   = make(map[string]*slog.LevelVar)
+	levelsMu sync.Mutex
+
+	out  = &output{stderr: os.Stderr}
+	base = slog.NewTextHandler(out, &slog.HandlerOptions{Level: slog.LevelDebug})
+)
+
+func init() {
+	for _, name := range Subsystems {
+		levelVar(name)
+	}
+	// std log calls of main and libs go to main subsystem
+	slog.SetDefault(For("main"))
+}
+
+func levelVar(name string) *slog.LevelVar {
+	levelsMu.Lock()
+	defer levelsMu.Unlock()
+	lv, ok := levels[name]
+	if !ok {
+		lv = new(slog.LevelVar)
+		levels[name] = lv
+	}
+	return lv
+}
+
+// For returns logger of subsystem, records have subsystem attr and are filtered by its level
+func For(subsystem string) *slog.Logger {
+	return slog.New(&handler{
+		Handler: base.WithAttrs([]slog.Attr{slog.String("subsystem", subsystem)}),
+		level:   levelVar(subsystem),
+	})
+}
+
+type handler struct {
+	slog.Handler
+	level *slog.LevelVar
+}
+
+func (h *handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &handler{Handler: h.Handler.WithAttrs(attrs), level: h.level}
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return &handler{Handler: h.Handler.WithGroup(name), level: h.level}
+}
+
+// ParseLevel parses level name: debug, info, warn, error
+func ParseLevel(s string) (slog.Level, error) {
+	var l slog.Level
+	err := l.UnmarshalText([]byte(s))
+	return l, err
+}
+
+// SetLevel changes level of subsystem
+func SetLevel(subsystem, level string) error {
+	l, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(Subsystems, subsystem) {
+		return fmt.Errorf("unknown log subsystem %q, available: %s", subsystem, strings.Join(Subsystems, ", "))
+	}
+	levelVar(subsystem).Set(l)
+	return nil
+}
+
+// Levels returns levels of all subsystems
+func Levels() map[string]string {
+	levelsMu.Lock()
+	defer levelsMu.Unlock()
+	ret := make(map[string]string, len(levels))
+	for name, lv := range levels {
+		ret[name] = strings.ToLower(lv.Level().String())
+	}
+	return ret
+}
+
+// Configure sets levels of subsystems, subsystems not in list get info or debug level.
+// Log is written to rotating file at path too, empty path - stderr only.
+func Configure(subsystemLevels map[string]string, debug bool, path string) error {
+	def := slog.LevelInfo
+	if debug {
+		def = slog.LevelDebug
+	}
+	var errs []string
+	for _, name := range Subsystems {
+		l := def
+		if s, ok := subsystemLevels[name]; ok && s != "" {
+			var err error
+			if l, err = ParseLevel(s); err != nil {
+				errs = append(errs, name+": "+err.Error())
+				l = def
+			}
+		}
+		levelVar(name).Set(l)
+	}
+	if err := SetFile(path); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("configure log: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// SetFile starts writing log to rotating file at path, empty path stops it
+func SetFile(path string) error {
+	return out.setFile(path)
+}
+
+// File returns path of log file, empty if log is not written to file
+func File() string {
+	out.mu.Lock()
+	defer out.mu.Unlock()
+	if out.file == nil {
+		return ""
+	}
+	return out.file.path
+}
+
+// output writes log records to stderr and optional log file
+type output struct {
+	stderr io.Writer
+	file   *rotateFile
+	mu     sync.Mutex
+}
+
+func (o *output) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.file != nil {
+		// file errors must not break logging to stderr
+		o.file.Write(p)
+	}
+	return o.stderr.Write(p)
+}
+
+func (o *output) setFile(path string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.file != nil {
+		if o.file.path == path {
+			return nil
+		}
+		o.file.Close()
+		o.file = nil
+	}
+	if path == "" {
+		return nil
+	}
+	f, err := openRotateFile(path)
+	if err != nil {
+		return err
+	}
+	o.file = f
+	return nil
+}
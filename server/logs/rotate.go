@@ -0,0 +1,74 @@
+package logs
+
+import (
+	"fmt"
+	"os"
+)
+
+const (
+	maxFileSize = 10 * 1024 * 1024
+	maxBackups  = 3
+)
+
+// rotateFile is append only log file, renamed to path.1 .. path.N when it grows over maxFileSize
+type rotateFile struct {
+	path string
+	f    *os.File
+	size int64
+}
+
+func openRotateFile(path string) (*rotateFile, error) {
+	r := &rotateFile{path: path}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotateFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f = f
+	r.size = st.Size()
+	return nil
+}
+
+func (r *rotateFile) Write(p []byte) (int, error) {
+	if r.f == nil {
+		return 0, os.ErrClosed
+	}
+	if r.size > 0 && r.size+int64(len(p)) > maxFileSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotateFile) rotate() error {
+	r.f.Close()
+	r.f = nil
+	for i := maxBackups - 1; i > 0; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+	}
+	os.Rename(r.path, r.path+".1")
+	return r.open()
+}
+
+func (r *rotateFile) Close() error {
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
@@ -0,0 +1,106 @@
+package media
+
+import (
+	"errors"
+	"io"
+	"strconv"
+)
+
+const (
+	FormatMKV = "matroska"
+	FormatMP4 = "mp4"
+	FormatTS  = "mpegts"
+)
+
+var ErrUnknownFormat = errors.New("unknown container format")
+
+type Keyframe struct {
+	Offset int64   // byte offset in file
+	Time   float64 // presentation time in seconds
+}
+
+type Index struct {
+	Format   string
+	Size     int64
+	Duration float64 // in seconds
+
+	// fragmented mp4 only
+	Fragmented bool
+	InitSize   int64 // length of ftyp+moov initialization section
+
+	Keyframes []Keyframe
+	DataEnd   int64 // end of data covered by last keyframe
+
+	ts *tsStream // mpeg-ts only
+}
+
+// BitRate returns average bitrate in bits per second
+func (i *Index) BitRate() int64 {
+	if i.Duration <= 0 {
+		return 0
+	}
+	return int64(float64(i.Size*8) / i.Duration)
+}
+
+func (i *Index) BitRateString() string {
+	if br := i.BitRate(); br > 0 {
+		return strconv.FormatInt(br, 10)
+	}
+	return ""
+}
+
+// Detect returns container format by file magic
+func Detect(r io.ReadSeeker) (string, error) {
+	buf := make([]byte, 200)
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	n, err := io.ReadFull(r, buf)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", err
+	}
+	buf = buf[:n]
+	switch {
+	case len(buf) >= 4 && buf[0] == 0x1A && buf[1] == 0x45 && buf[2] == 0xDF && buf[3] == 0xA3:
+		return FormatMKV, nil
+	case len(buf) >= 8 && isMP4Box(string(buf[4:8])):
+		return FormatMP4, nil
+	case tsPacketLen(buf) > 0:
+		return FormatTS, nil
+	}
+	return "", ErrUnknownFormat
+}
+
+// ParseIndex reads container index of file, only required ranges are read
+func ParseIndex(r io.ReadSeeker, size int64) (*Index, error) {
+	format, err := Detect(r)
+	if err != nil {
+		return nil, err
+	}
+	var idx *Index
+	switch format {
+	case FormatMKV:
+		idx, err = parseMKVIndex(r, size)
+	case FormatMP4:
+		idx, err = parseMP4Index(r, size)
+	case FormatTS:
+		idx, err = parseTSIndex(r, size)
+	}
+	if err != nil {
+		return nil, err
+	}
+	idx.Format = format
+	idx.Size = size
+	if idx.DataEnd == 0 {
+		idx.DataEnd = size
+	}
+	return idx, nil
+}
+
+func readAt(r io.ReadSeeker, off int64, buf []byte) error {
+	if _, err := r.Seek(off, io.SeekStart); err != nil {
+		return err
+	}
+	_, err := io.ReadFull(r, buf)
+	return err
+}
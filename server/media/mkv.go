@@ -0,0 +1,823 @@
+package media
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	mkvEBML          = 0x1A45DFA3
+	mkvSegment       = 0x18538067
+	mkvSeekHead      = 0x114D9B74
+	mkvSeek          = 0x4DBB
+	mkvSeekID        = 0x53AB
+	mkvSeekPosition  = 0x53AC
+	mkvInfo          = 0x1549A966
+	mkvTimecodeScale = 0x2AD7B1
+	mkvDuration      = 0x4489
+	mkvTracks        = 0x1654AE6B
+	mkvCues          = 0x1C53BB6B
+	mkvCuePoint      = 0xBB
+	mkvCueTime       = 0xB3
+	mkvCuePositions  = 0xB7
+	mkvCueTrack      = 0xF7
+	mkvCueClusterPos = 0xF1
+	mkvCueRelPos     = 0xF0
+	mkvCluster       = 0x1F43B675
+
+	mkvUnknownSize = -1
+	maxMKVElement  = 64 << 20
+)
+
+type mkvElement struct {
+	id   uint32
+	data []byte
+}
+
+// ebmlVint decodes variable size integer, keepMarker is used for element ids
+func ebmlVint(buf []byte, keepMarker bool) (val uint64, n int, err error) {
+	if len(buf) == 0 {
+		return 0, 0, io.ErrUnexpectedEOF
+	}
+	first := buf[0]
+	n = 1
+	for mask := byte(0x80); n <= 8 && first&mask == 0; mask >>= 1 {
+		n++
+	}
+	if n > 8 {
+		return 0, 0, errors.New("wrong ebml vint")
+	}
+	if len(buf) < n {
+		return 0, 0, io.ErrUnexpectedEOF
+	}
+	val = uint64(first)
+	if !keepMarker {
+		val &= uint64(0xFF >> n)
+	}
+	allOnes := val == uint64(0xFF>>n)
+	for i := 1; i < n; i++ {
+		val = val<<8 | uint64(buf[i])
+		allOnes = allOnes && buf[i] == 0xFF
+	}
+	if !keepMarker && allOnes {
+		return math.MaxUint64, n, nil
+	}
+	return val, n, nil
+}
+
+// ebmlHeader parses element id and size, size is mkvUnknownSize for live streams
+func ebmlHeader(buf []byte) (id uint32, size int64, n int, err error) {
+	v, l1, err := ebmlVint(buf, true)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	s, l2, err := ebmlVint(buf[l1:], false)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	size = int64(s)
+	if s == math.MaxUint64 {
+		size = mkvUnknownSize
+	}
+	return uint32(v), size, l1 + l2, nil
+}
+
+func readEBMLHeader(r io.ReadSeeker, off int64) (id uint32, size int64, n int, err error) {
+	buf := make([]byte, 12)
+	if _, err = r.Seek(off, io.SeekStart); err != nil {
+		return
+	}
+	l, err := io.ReadFull(r, buf)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return
+	}
+	return ebmlHeader(buf[:l])
+}
+
+func readEBMLElement(r io.ReadSeeker, off int64) (uint32, []byte, error) {
+	id, size, n, err := readEBMLHeader(r, off)
+	if err != nil {
+		return 0, nil, err
+	}
+	if size < 0 || size > maxMKVElement {
+		return 0, nil, errors.New("wrong mkv element size")
+	}
+	buf := make([]byte, size)
+	if err = readAt(r, off+int64(n), buf); err != nil {
+		return 0, nil, err
+	}
+	return id, buf, nil
+}
+
+// mkvElements splits master element data to children
+func mkvElements(buf []byte) []mkvElement {
+	var els []mkvElement
+	for len(buf) > 0 {
+		id, size, n, err := ebmlHeader(buf)
+		if err != nil || size < 0 || int64(len(buf)-n) < size {
+			break
+		}
+		els = append(els, mkvElement{id, buf[n : n+int(size)]})
+		buf = buf[n+int(size):]
+	}
+	return els
+}
+
+func mkvUint(buf []byte) uint64 {
+	var v uint64
+	for _, b := range buf {
+		v = v<<8 | uint64(b)
+	}
+	return v
+}
+
+func mkvFloat(buf []byte) float64 {
+	switch len(buf) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(buf)))
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(buf))
+	}
+	return 0
+}
+
+// mkvSegmentInfo holds top level elements of segment read before first cluster
+type mkvSegmentInfo struct {
+	dataStart  int64
+	info       []byte
+	tracks     []byte
+	cuesPos    int64 // absolute, 0 if unknown
+	clusterPos int64 // first cluster, 0 if not found
+}
+
+func readMKVSegment(r io.ReadSeeker, size int64) (*mkvSegmentInfo, error) {
+	id, hsize, n, err := readEBMLHeader(r, 0)
+	if err != nil {
+		return nil, err
+	}
+	if id != mkvEBML || hsize < 0 {
+		return nil, errors.New("not matroska")
+	}
+	off := int64(n) + hsize
+
+	id, _, n, err = readEBMLHeader(r, off)
+	if err != nil {
+		return nil, err
+	}
+	if id != mkvSegment {
+		return nil, errors.New("matroska has no segment")
+	}
+	seg := &mkvSegmentInfo{dataStart: off + int64(n)}
+
+	for off = seg.dataStart; off < size; {
+		id, esize, n, err := readEBMLHeader(r, off)
+		if err != nil {
+			return nil, err
+		}
+		if id == mkvCluster {
+			seg.clusterPos = off
+			break
+		}
+		if esize < 0 {
+			break
+		}
+		switch id {
+		case mkvSeekHead, mkvInfo, mkvTracks:
+			_, data, err := readEBMLElement(r, off)
+			if err != nil {
+				return nil, err
+			}
+			switch id {
+			case mkvSeekHead:
+				for _, seek := range mkvElements(data) {
+					if seek.id != mkvSeek {
+						continue
+					}
+					var sid uint32
+					var pos int64 = -1
+					for _, el := range mkvElements(seek.data) {
+						switch el.id {
+						case mkvSeekID:
+							sid = uint32(mkvUint(el.data))
+						case mkvSeekPosition:
+							pos = int64(mkvUint(el.data))
+						}
+					}
+					if sid == mkvCues && pos >= 0 {
+						seg.cuesPos = seg.dataStart + pos
+					}
+				}
+			case mkvInfo:
+				seg.info = data
+			case mkvTracks:
+				seg.tracks = data
+			}
+		case mkvCues:
+			seg.cuesPos = off
+		}
+		off += int64(n) + esize
+	}
+	return seg, nil
+}
+
+// timecodeScale returns nanoseconds in timecode unit
+func (seg *mkvSegmentInfo) timecodeScale() uint64 {
+	for _, el := range mkvElements(seg.info) {
+		if el.id == mkvTimecodeScale {
+			if scale := mkvUint(el.data); scale > 0 {
+				return scale
+			}
+		}
+	}
+	return 1000000
+}
+
+func parseMKVIndex(r io.ReadSeeker, size int64) (*Index, error) {
+	seg, err := readMKVSegment(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	idx := new(Index)
+	scale := seg.timecodeScale()
+	for _, el := range mkvElements(seg.info) {
+		if el.id == mkvDuration {
+			idx.Duration = mkvFloat(el.data)
+		}
+	}
+	idx.Duration = idx.Duration * float64(scale) / 1e9
+
+	if seg.cuesPos == 0 {
+		return idx, nil
+	}
+	id, cues, err := readEBMLElement(r, seg.cuesPos)
+	if err != nil {
+		return nil, err
+	}
+	if id != mkvCues {
+		return idx, nil
+	}
+
+	// cue points of first cued track, usually video
+	var cueTrack uint64
+	var last int64 = -1
+	for _, cp := range mkvCuePoints(cues, seg.dataStart) {
+		if cueTrack == 0 {
+			cueTrack = cp.track
+		}
+		if cp.track != cueTrack || cp.cluster == last {
+			continue
+		}
+		last = cp.cluster
+		idx.Keyframes = append(idx.Keyframes, Keyframe{
+			Offset: last,
+			Time:   float64(cp.time*scale) / 1e9,
+		})
+	}
+	if seg.cuesPos > seg.dataStart && len(idx.Keyframes) > 0 && seg.cuesPos > idx.Keyframes[len(idx.Keyframes)-1].Offset {
+		idx.DataEnd = seg.cuesPos
+	}
+	return idx, nil
+}
+
+type mkvCuePos struct {
+	time    uint64
+	track   uint64
+	cluster int64 // absolute
+	block   int64 // relative to cluster data, -1 if unknown
+}
+
+// mkvCuePoints parses positions of cues element, positions without cluster are skipped
+func mkvCuePoints(cues []byte, dataStart int64) []mkvCuePos {
+	var ret []mkvCuePos
+	for _, cp := range mkvElements(cues) {
+		if cp.id != mkvCuePoint {
+			continue
+		}
+		var cueTime uint64
+		for _, el := range mkvElements(cp.data) {
+			switch el.id {
+			case mkvCueTime:
+				cueTime = mkvUint(el.data)
+			case mkvCuePositions:
+				pt := mkvCuePos{time: cueTime, cluster: -1, block: -1}
+				for _, p := range mkvElements(el.data) {
+					switch p.id {
+					case mkvCueTrack:
+						pt.track = mkvUint(p.data)
+					case mkvCueClusterPos:
+						pt.cluster = dataStart + int64(mkvUint(p.data))
+					case mkvCueRelPos:
+						pt.block = int64(mkvUint(p.data))
+					}
+				}
+				if pt.cluster >= dataStart {
+					ret = append(ret, pt)
+				}
+			}
+		}
+	}
+	return ret
+}
+
+const (
+	mkvTrackEntry        = 0xAE
+	mkvTrackNumber       = 0xD7
+	mkvTrackType         = 0x83
+	mkvCodecID           = 0x86
+	mkvCodecPrivate      = 0x63A2
+	mkvTrackName         = 0x536E
+	mkvLanguage          = 0x22B59C
+	mkvLanguageIETF      = 0x22B59D
+	mkvFlagDefault       = 0x88
+	mkvFlagForced        = 0x55AA
+	mkvVideo             = 0xE0
+	mkvPixelWidth        = 0xB0
+	mkvPixelHeight       = 0xBA
+	mkvAudio             = 0xE1
+	mkvSamplingFrequency = 0xB5
+	mkvChannels          = 0x9F
+	mkvContentEncodings  = 0x6D80
+	mkvContentEncoding   = 0x6240
+	mkvContentComp       = 0x5034
+	mkvContentCompAlgo   = 0x4254
+	mkvContentCompSet    = 0x4255
+)
+
+var mkvCodecs = map[string]string{
+	"V_MPEG4/ISO/AVC":  "h264",
+	"V_MPEGH/ISO/HEVC": "hevc",
+	"V_AV1":            "av1",
+	"V_VP8":            "vp8",
+	"V_VP9":            "vp9",
+	"V_MPEG1":          "mpeg1video",
+	"V_MPEG2":          "mpeg2video",
+	"V_MPEG4/ISO/ASP":  "mpeg4",
+	"V_MPEG4/ISO/SP":   "mpeg4",
+	"V_MPEG4/MS/V3":    "msmpeg4v3",
+	"V_MS/VFW/FOURCC":  "mpeg4",
+	"V_THEORA":         "theora",
+	"A_AAC":            "aac",
+	"A_AC3":            "ac3",
+	"A_EAC3":           "eac3",
+	"A_DTS":            "dts",
+	"A_TRUEHD":         "truehd",
+	"A_FLAC":           "flac",
+	"A_OPUS":           "opus",
+	"A_VORBIS":         "vorbis",
+	"A_MPEG/L2":        "mp2",
+	"A_MPEG/L3":        "mp3",
+	"A_PCM/INT/LIT":    "pcm_s16le",
+	"A_PCM/INT/BIG":    "pcm_s16be",
+	"S_TEXT/UTF8":      "subrip",
+	"S_TEXT/ASS":       "ass",
+	"S_TEXT/SSA":       "ssa",
+	"S_ASS":            "ass",
+	"S_SSA":            "ssa",
+	"S_TEXT/WEBVTT":    "webvtt",
+	"S_HDMV/PGS":       "hdmv_pgs_subtitle",
+	"S_VOBSUB":         "dvd_subtitle",
+	"S_DVBSUB":         "dvb_subtitle",
+}
+
+type mkvTrack struct {
+	number  uint64
+	codecID string
+	private []byte
+	stream  *Stream
+
+	// content compression of blocks, -1 if none
+	compAlgo     int
+	compSettings []byte
+}
+
+func mkvCodecName(codecID string) string {
+	if name, ok := mkvCodecs[codecID]; ok {
+		return name
+	}
+	// A_AAC/MPEG4/LC, A_DTS/EXPRESS ...
+	for id, name := range mkvCodecs {
+		if len(codecID) > len(id) && codecID[:len(id)+1] == id+"/" {
+			return name
+		}
+	}
+	return ""
+}
+
+func parseMKVTracks(tracks []byte) []*mkvTrack {
+	var ret []*mkvTrack
+	for _, te := range mkvElements(tracks) {
+		if te.id != mkvTrackEntry {
+			continue
+		}
+		tr := &mkvTrack{compAlgo: -1}
+		var typ uint64
+		lang, langIETF, name := "eng", "", ""
+		isDefault, isForced := uint64(1), uint64(0)
+		var width, height, channels uint64
+		var sampleRate float64
+		for _, el := range mkvElements(te.data) {
+			switch el.id {
+			case mkvTrackNumber:
+				tr.number = mkvUint(el.data)
+			case mkvTrackType:
+				typ = mkvUint(el.data)
+			case mkvCodecID:
+				tr.codecID = string(el.data)
+			case mkvCodecPrivate:
+				tr.private = el.data
+			case mkvTrackName:
+				name = string(el.data)
+			case mkvLanguage:
+				lang = string(el.data)
+			case mkvLanguageIETF:
+				langIETF = string(el.data)
+			case mkvFlagDefault:
+				isDefault = mkvUint(el.data)
+			case mkvFlagForced:
+				isForced = mkvUint(el.data)
+			case mkvVideo:
+				for _, v := range mkvElements(el.data) {
+					switch v.id {
+					case mkvPixelWidth:
+						width = mkvUint(v.data)
+					case mkvPixelHeight:
+						height = mkvUint(v.data)
+					}
+				}
+			case mkvAudio:
+				for _, a := range mkvElements(el.data) {
+					switch a.id {
+					case mkvSamplingFrequency:
+						sampleRate = mkvFloat(a.data)
+					case mkvChannels:
+						channels = mkvUint(a.data)
+					}
+				}
+			case mkvContentEncodings:
+				tr.parseEncodings(el.data)
+			}
+		}
+
+		var codecType string
+		switch typ {
+		case 1:
+			codecType = CodecTypeVideo
+		case 2:
+			codecType = CodecTypeAudio
+		case 17:
+			codecType = CodecTypeSubtitle
+		default:
+			continue
+		}
+		s := newStream(codecType, mkvCodecName(tr.codecID))
+		s.CodecTag = tr.codecID
+		s.Width, s.Height = int(width), int(height)
+		if sampleRate > 0 {
+			s.SampleRate = strconv.Itoa(int(sampleRate))
+		}
+		s.Channels = int(channels)
+		s.ChannelLayout = channelLayout(s.Channels)
+		s.Disposition["default"] = int(isDefault)
+		s.Disposition["forced"] = int(isForced)
+		s.Tags["language"] = lang
+		if langIETF != "" {
+			s.Tags["language_ietf"] = langIETF
+		}
+		if name != "" {
+			s.Tags["title"] = name
+		}
+		tr.stream = s
+		ret = append(ret, tr)
+	}
+	return ret
+}
+
+func probeMKV(r io.ReadSeeker, size int64) ([]*Stream, error) {
+	seg, err := readMKVSegment(r, size)
+	if err != nil {
+		return nil, err
+	}
+	var streams []*Stream
+	for _, tr := range parseMKVTracks(seg.tracks) {
+		streams = append(streams, tr.stream)
+	}
+	return streams, nil
+}
+
+func (tr *mkvTrack) parseEncodings(buf []byte) {
+	for _, enc := range mkvElements(buf) {
+		if enc.id != mkvContentEncoding {
+			continue
+		}
+		for _, el := range mkvElements(enc.data) {
+			if el.id != mkvContentComp {
+				continue
+			}
+			tr.compAlgo = 0 // zlib by default
+			for _, c := range mkvElements(el.data) {
+				switch c.id {
+				case mkvContentCompAlgo:
+					tr.compAlgo = int(mkvUint(c.data))
+				case mkvContentCompSet:
+					tr.compSettings = c.data
+				}
+			}
+		}
+	}
+}
+
+// decode restores block payload compressed by zlib or header stripping
+func (tr *mkvTrack) decode(payload []byte) ([]byte, error) {
+	switch tr.compAlgo {
+	case -1:
+		return payload, nil
+	case 0:
+		zr, err := zlib.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		return io.ReadAll(io.LimitReader(zr, maxMKVElement))
+	case 3:
+		return append(append([]byte{}, tr.compSettings...), payload...), nil
+	}
+	return nil, errors.New("unsupported mkv content compression")
+}
+
+// cueText converts block payload of text subtitle track
+func (tr *mkvTrack) cueText(payload []byte) string {
+	switch tr.stream.CodecName {
+	case "ass", "ssa":
+		// ReadOrder, Layer, Style, Name, MarginL, MarginR, MarginV, Effect, Text
+		fields := strings.SplitN(string(payload), ",", 9)
+		if len(fields) < 9 {
+			return ""
+		}
+		return assText(fields[8])
+	case "subrip":
+		return strings.TrimSpace(assTagRe.ReplaceAllString(subText(payload), ""))
+	}
+	return strings.TrimSpace(subText(payload))
+}
+
+const (
+	mkvClusterTimecode = 0xE7
+	mkvSimpleBlock     = 0xA3
+	mkvBlockGroup      = 0xA0
+	mkvBlock           = 0xA1
+	mkvBlockDuration   = 0x9B
+
+	// used for blocks without duration
+	defaultCueDuration = 3 * time.Second
+
+	maxMKVSubtitleWalk = 256 << 20
+)
+
+// blockTrack reads track number of block at offset without reading block data
+func blockTrack(r io.ReadSeeker, off int64) (uint64, error) {
+	buf := make([]byte, 8)
+	if _, err := r.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	l, err := io.ReadFull(r, buf)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return 0, err
+	}
+	track, _, err := ebmlVint(buf[:l], false)
+	return track, err
+}
+
+// groupTrack reads track number of block in block group
+func groupTrack(r io.ReadSeeker, off, end int64) (uint64, error) {
+	for off < end {
+		id, size, n, err := readEBMLHeader(r, off)
+		if err != nil {
+			return 0, err
+		}
+		if size < 0 {
+			break
+		}
+		if id == mkvBlock {
+			return blockTrack(r, off+int64(n))
+		}
+		off += int64(n) + size
+	}
+	return 0, errors.New("mkv block group has no block")
+}
+
+// blockCue parses block of subtitle track, time is in timecode units
+func (tr *mkvTrack) blockCue(block []byte, clusterTime uint64, duration uint64, scale uint64) (Cue, bool) {
+	_, n, err := ebmlVint(block, false)
+	if err != nil || len(block) < n+3 {
+		return Cue{}, false
+	}
+	rel := int64(int16(binary.BigEndian.Uint16(block[n:])))
+	payload, err := tr.decode(block[n+3:])
+	if err != nil {
+		return Cue{}, false
+	}
+	start := time.Duration((int64(clusterTime) + rel) * int64(scale))
+	cue := Cue{
+		Start: start,
+		End:   start + defaultCueDuration,
+		Text:  tr.cueText(payload),
+	}
+	if duration > 0 {
+		cue.End = start + time.Duration(duration*scale)
+	}
+	return cue, cue.Text != ""
+}
+
+// ReadMKVSubtitles extracts cues of embedded text subtitle stream, stream is index of stream reported by Probe.
+// Blocks are read by cues of subtitle track, so only pieces of file having subtitles are read.
+// Files without such cues are walked by clusters reading headers of all blocks, up to maxMKVSubtitleWalk size
+func ReadMKVSubtitles(r io.ReadSeeker, size int64, stream int) ([]Cue, error) {
+	seg, err := readMKVSegment(r, size)
+	if err != nil {
+		return nil, err
+	}
+	tracks := parseMKVTracks(seg.tracks)
+	if stream < 0 || stream >= len(tracks) {
+		return nil, errors.New("wrong subtitle stream index")
+	}
+	tr := tracks[stream]
+	switch tr.stream.CodecName {
+	case "subrip", "ass", "ssa", "webvtt":
+	default:
+		return nil, errors.New("stream is not text subtitle")
+	}
+	scale := seg.timecodeScale()
+
+	var points []mkvCuePos
+	if seg.cuesPos > 0 {
+		if id, cues, err := readEBMLElement(r, seg.cuesPos); err == nil && id == mkvCues {
+			for _, cp := range mkvCuePoints(cues, seg.dataStart) {
+				if cp.track == tr.number {
+					points = append(points, cp)
+				}
+			}
+		}
+	}
+
+	var cues []Cue
+	if len(points) == 0 {
+		if size > maxMKVSubtitleWalk {
+			return nil, errors.New("matroska has no cues of subtitle track")
+		}
+		for off := seg.clusterPos; off > 0 && off < size; {
+			off = tr.readCluster(r, off, size, scale, &cues)
+		}
+		return cues, nil
+	}
+
+	// cue points of same cluster without block position are read once by cluster
+	seen := make(map[int64]bool)
+	for _, cp := range points {
+		if cp.block < 0 {
+			if !seen[cp.cluster] {
+				seen[cp.cluster] = true
+				tr.readCluster(r, cp.cluster, size, scale, &cues)
+			}
+			continue
+		}
+		clusterTime, data, err := readClusterTime(r, cp.cluster)
+		if err != nil || seen[data+cp.block] {
+			continue
+		}
+		seen[data+cp.block] = true
+		if cue, ok := tr.readBlockCue(r, data+cp.block, clusterTime, scale); ok {
+			cues = append(cues, cue)
+		}
+	}
+	sort.SliceStable(cues, func(i, j int) bool {
+		return cues[i].Start < cues[j].Start
+	})
+	return cues, nil
+}
+
+// readClusterTime returns timecode and data offset of cluster
+func readClusterTime(r io.ReadSeeker, off int64) (uint64, int64, error) {
+	id, size, n, err := readEBMLHeader(r, off)
+	if err != nil {
+		return 0, 0, err
+	}
+	if id != mkvCluster {
+		return 0, 0, errors.New("cue position is not mkv cluster")
+	}
+	data := off + int64(n)
+	end := data + size
+	for p := data; size < 0 || p < end; {
+		cid, csize, cn, err := readEBMLHeader(r, p)
+		if err != nil {
+			return 0, 0, err
+		}
+		switch cid {
+		case mkvClusterTimecode:
+			_, buf, err := readEBMLElement(r, p)
+			if err != nil {
+				return 0, 0, err
+			}
+			return mkvUint(buf), data, nil
+		case mkvSimpleBlock, mkvBlockGroup, mkvCluster:
+			return 0, 0, errors.New("mkv cluster has no timecode")
+		}
+		if csize < 0 {
+			break
+		}
+		p += int64(cn) + csize
+	}
+	return 0, 0, errors.New("mkv cluster has no timecode")
+}
+
+// readCluster appends cues of track in cluster or other top level element at off, returns offset of next element.
+// Only headers of blocks of other tracks are read
+func (tr *mkvTrack) readCluster(r io.ReadSeeker, off, size int64, scale uint64, cues *[]Cue) int64 {
+	id, esize, n, err := readEBMLHeader(r, off)
+	if err != nil {
+		return size
+	}
+	end := off + int64(n) + esize
+	if esize < 0 {
+		end = size
+	}
+	if id != mkvCluster {
+		if esize < 0 {
+			return size
+		}
+		return end
+	}
+
+	var clusterTime uint64
+	p := off + int64(n)
+	for p < end {
+		cid, csize, cn, err := readEBMLHeader(r, p)
+		if err != nil || csize < 0 {
+			return size
+		}
+		if cid == mkvCluster {
+			// end of cluster with unknown size
+			return p
+		}
+		switch cid {
+		case mkvClusterTimecode:
+			_, buf, err := readEBMLElement(r, p)
+			if err == nil {
+				clusterTime = mkvUint(buf)
+			}
+		case mkvSimpleBlock, mkvBlockGroup:
+			if cue, ok := tr.readBlockCue(r, p, clusterTime, scale); ok {
+				*cues = append(*cues, cue)
+			}
+		}
+		p += int64(cn) + csize
+	}
+	return end
+}
+
+// readBlockCue reads simple block or block group at off if it is block of track
+func (tr *mkvTrack) readBlockCue(r io.ReadSeeker, off int64, clusterTime, scale uint64) (Cue, bool) {
+	id, size, n, err := readEBMLHeader(r, off)
+	if err != nil || size < 0 {
+		return Cue{}, false
+	}
+	data := off + int64(n)
+	var track uint64
+	switch id {
+	case mkvSimpleBlock:
+		track, err = blockTrack(r, data)
+	case mkvBlockGroup:
+		track, err = groupTrack(r, data, data+size)
+	default:
+		return Cue{}, false
+	}
+	if err != nil || track != tr.number {
+		return Cue{}, false
+	}
+	_, buf, err := readEBMLElement(r, off)
+	if err != nil {
+		return Cue{}, false
+	}
+	if id == mkvSimpleBlock {
+		return tr.blockCue(buf, clusterTime, 0, scale)
+	}
+	var block []byte
+	var duration uint64
+	for _, el := range mkvElements(buf) {
+		switch el.id {
+		case mkvBlock:
+			block = el.data
+		case mkvBlockDuration:
+			duration = mkvUint(el.data)
+		}
+	}
+	return tr.blockCue(block, clusterTime, duration, scale)
+}
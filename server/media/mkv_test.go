@@ -0,0 +1,170 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// ebml builds element with 8 byte size
+func ebml(id uint32, data ...[]byte) []byte {
+	body := bytes.Join(data, nil)
+	var buf []byte
+	for shift := 24; shift >= 0; shift -= 8 {
+		if b := byte(id >> shift); b != 0 || len(buf) > 0 {
+			buf = append(buf, b)
+		}
+	}
+	buf = append(buf, 0x01)
+	buf = append(buf, binary.BigEndian.AppendUint64(nil, uint64(len(body)))[1:]...)
+	return append(buf, body...)
+}
+
+func ebmlUint(v uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, v)
+}
+
+func blockData(track byte, rel int16, payload []byte) []byte {
+	return append([]byte{0x80 | track, byte(rel >> 8), byte(rel), 0x80}, payload...)
+}
+
+type testMKV struct {
+	data     []byte
+	clusters []int64  // absolute offsets
+	video    [2]int64 // payload range of video block of second cluster
+}
+
+// buildMKV has h264 track 1 and subrip track 2 in 3 clusters at 0, 5 and 8 seconds,
+// second cluster has no subtitles. Subtitle cue of first cluster has block position, one of third cluster has not
+func buildMKV(withCues bool) *testMKV {
+	video := bytes.Repeat([]byte{0xAA}, 100<<10)
+	info := ebml(mkvInfo, ebml(mkvTimecodeScale, ebmlUint(1000000)), ebml(mkvDuration, binary.BigEndian.AppendUint64(nil, math.Float64bits(10000))))
+	tracks := ebml(mkvTracks,
+		ebml(mkvTrackEntry, ebml(mkvTrackNumber, []byte{1}), ebml(mkvTrackType, []byte{1}), ebml(mkvCodecID, []byte("V_MPEG4/ISO/AVC"))),
+		ebml(mkvTrackEntry, ebml(mkvTrackNumber, []byte{2}), ebml(mkvTrackType, []byte{17}), ebml(mkvCodecID, []byte("S_TEXT/UTF8")), ebml(mkvLanguage, []byte("rus"))),
+	)
+	group := ebml(mkvBlockGroup, ebml(mkvBlock, blockData(2, 1000, []byte("Hello"))), ebml(mkvBlockDuration, ebmlUint(2000)))
+	clusters := [][]byte{
+		ebml(mkvCluster, ebml(mkvClusterTimecode, ebmlUint(0)), ebml(mkvSimpleBlock, blockData(1, 0, video)), group),
+		ebml(mkvCluster, ebml(mkvClusterTimecode, ebmlUint(5000)), ebml(mkvSimpleBlock, blockData(1, 0, video))),
+		ebml(mkvCluster, ebml(mkvClusterTimecode, ebmlUint(8000)), ebml(mkvSimpleBlock, blockData(2, 500, []byte("<b>Bye</b>"))), ebml(mkvSimpleBlock, blockData(1, 0, video))),
+	}
+	// seek head has fixed size, so positions are known before it is built
+	seekHead := func(cues int64) []byte {
+		return ebml(mkvSeekHead, ebml(mkvSeek, ebml(mkvSeekID, []byte{0x1C, 0x53, 0xBB, 0x6B}), ebml(mkvSeekPosition, ebmlUint(uint64(cues)))))
+	}
+	rel := make([]int64, len(clusters))
+	pos := int64(len(seekHead(0)) + len(info) + len(tracks))
+	for i, c := range clusters {
+		rel[i] = pos
+		pos += int64(len(c))
+	}
+	cuePos := func(track byte, cluster int64, block int64) []byte {
+		els := [][]byte{ebml(mkvCueTrack, []byte{track}), ebml(mkvCueClusterPos, ebmlUint(uint64(cluster)))}
+		if block >= 0 {
+			els = append(els, ebml(mkvCueRelPos, ebmlUint(uint64(block))))
+		}
+		return ebml(mkvCuePositions, els...)
+	}
+	// block group follows timecode and video block in first cluster
+	groupRel := int64(len(ebml(mkvClusterTimecode, ebmlUint(0))) + len(ebml(mkvSimpleBlock, blockData(1, 0, video))))
+	cues := ebml(mkvCues,
+		ebml(mkvCuePoint, ebml(mkvCueTime, ebmlUint(0)), cuePos(1, rel[0], 0)),
+		ebml(mkvCuePoint, ebml(mkvCueTime, ebmlUint(1000)), cuePos(2, rel[0], groupRel)),
+		ebml(mkvCuePoint, ebml(mkvCueTime, ebmlUint(5000)), cuePos(1, rel[1], 0)),
+		ebml(mkvCuePoint, ebml(mkvCueTime, ebmlUint(8500)), cuePos(2, rel[2], -1)),
+	)
+
+	body := [][]byte{seekHead(pos), info, tracks}
+	body = append(body, clusters...)
+	if withCues {
+		body = append(body, cues)
+	} else {
+		body[0] = ebml(0xEC, make([]byte, len(seekHead(0))-9)) // void
+	}
+	header := ebml(mkvEBML, ebml(0x4282, []byte("matroska")))
+	segment := ebml(mkvSegment, body...)
+	dataStart := int64(len(header) + 12)
+
+	m := &testMKV{data: append(header, segment...)}
+	for _, r := range rel {
+		m.clusters = append(m.clusters, dataStart+r)
+	}
+	videoStart := m.clusters[1] + int64(len(ebml(mkvCluster))) + int64(len(ebml(mkvClusterTimecode, ebmlUint(0)))) + int64(len(ebml(mkvSimpleBlock))) + 4
+	m.video = [2]int64{videoStart, videoStart + int64(len(video))}
+	return m
+}
+
+// readLog records read ranges of reader
+type readLog struct {
+	*bytes.Reader
+	reads [][2]int64
+}
+
+func (r *readLog) Read(p []byte) (int, error) {
+	pos, _ := r.Seek(0, io.SeekCurrent)
+	n, err := r.Reader.Read(p)
+	r.reads = append(r.reads, [2]int64{pos, pos + int64(n)})
+	return n, err
+}
+
+func TestParseMKVIndex(t *testing.T) {
+	m := buildMKV(true)
+	idx, err := ParseIndex(bytes.NewReader(m.data), int64(len(m.data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idx.Format != FormatMKV || idx.Duration != 10 {
+		t.Errorf("format %q duration %v, want matroska 10", idx.Format, idx.Duration)
+	}
+	want := []Keyframe{{m.clusters[0], 0}, {m.clusters[1], 5}}
+	if !reflect.DeepEqual(idx.Keyframes, want) {
+		t.Errorf("keyframes %v, want %v", idx.Keyframes, want)
+	}
+}
+
+func TestProbeMKV(t *testing.T) {
+	m := buildMKV(true)
+	streams, err := probeMKV(bytes.NewReader(m.data), int64(len(m.data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(streams) != 2 || streams[0].CodecName != "h264" || streams[1].CodecName != "subrip" || streams[1].Tags["language"] != "rus" {
+		t.Errorf("wrong streams %+v %+v", streams[0], streams[1])
+	}
+}
+
+func TestReadMKVSubtitles(t *testing.T) {
+	want := []Cue{
+		{time.Second, 3 * time.Second, "Hello"},
+		{8500 * time.Millisecond, 11500 * time.Millisecond, "<b>Bye</b>"},
+	}
+	for _, withCues := range []bool{true, false} {
+		m := buildMKV(withCues)
+		r := &readLog{Reader: bytes.NewReader(m.data)}
+		cues, err := ReadMKVSubtitles(r, int64(len(m.data)), 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(cues, want) {
+			t.Errorf("cues %v: got %+v, want %+v", withCues, cues, want)
+		}
+		// walk without cues reads block headers of all clusters
+		read := false
+		for _, rd := range r.reads {
+			read = read || rd[0] < m.video[1] && rd[1] > m.video[0]
+		}
+		if read == withCues {
+			t.Errorf("cues %v: cluster without subtitles is read %v", withCues, read)
+		}
+	}
+
+	m := buildMKV(true)
+	if _, err := ReadMKVSubtitles(bytes.NewReader(m.data), int64(len(m.data)), 0); err == nil {
+		t.Error("video stream is read as subtitles")
+	}
+}
@@ -0,0 +1,458 @@
+package media
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"strconv"
+)
+
+const maxMoovSize = 64 << 20
+
+func isMP4Box(typ string) bool {
+	switch typ {
+	case "ftyp", "moov", "free", "skip", "mdat", "wide", "pnot", "styp":
+		return true
+	}
+	return false
+}
+
+type mp4Box struct {
+	typ  string
+	data []byte
+}
+
+// mp4Boxes splits buffer to child boxes
+func mp4Boxes(buf []byte) []mp4Box {
+	var boxes []mp4Box
+	for len(buf) >= 8 {
+		size := int64(binary.BigEndian.Uint32(buf))
+		typ := string(buf[4:8])
+		hdr := int64(8)
+		if size == 1 {
+			if len(buf) < 16 {
+				break
+			}
+			size = int64(binary.BigEndian.Uint64(buf[8:]))
+			hdr = 16
+		} else if size == 0 {
+			size = int64(len(buf))
+		}
+		if size < hdr || size > int64(len(buf)) {
+			break
+		}
+		boxes = append(boxes, mp4Box{typ, buf[hdr:size]})
+		buf = buf[size:]
+	}
+	return boxes
+}
+
+func mp4Child(buf []byte, path ...string) []byte {
+	for _, p := range path {
+		found := false
+		for _, b := range mp4Boxes(buf) {
+			if b.typ == p {
+				buf = b.data
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil
+		}
+	}
+	return buf
+}
+
+func readMP4BoxHeader(r io.ReadSeeker, off int64) (size int64, typ string, hdr int64, err error) {
+	buf := make([]byte, 16)
+	if err = readAt(r, off, buf[:8]); err != nil {
+		return
+	}
+	size = int64(binary.BigEndian.Uint32(buf))
+	typ = string(buf[4:8])
+	hdr = 8
+	if size == 1 {
+		if _, err = io.ReadFull(r, buf[8:16]); err != nil {
+			return
+		}
+		size = int64(binary.BigEndian.Uint64(buf[8:]))
+		hdr = 16
+	}
+	return
+}
+
+// readMP4Top walks top level boxes and reads moov and first sidx
+func readMP4Top(r io.ReadSeeker, size int64) (moov []byte, moovEnd int64, sidx []byte, sidxEnd int64, err error) {
+	for off := int64(0); off < size; {
+		bsize, typ, hdr, err := readMP4BoxHeader(r, off)
+		if err != nil {
+			return nil, 0, nil, 0, err
+		}
+		if bsize == 0 {
+			bsize = size - off
+		}
+		if bsize < hdr {
+			return nil, 0, nil, 0, errors.New("broken mp4 box " + typ)
+		}
+		switch typ {
+		case "moov":
+			if bsize-hdr > maxMoovSize {
+				return nil, 0, nil, 0, errors.New("mp4 moov too big")
+			}
+			moov = make([]byte, bsize-hdr)
+			if _, err = io.ReadFull(r, moov); err != nil {
+				return nil, 0, nil, 0, err
+			}
+			moovEnd = off + bsize
+		case "sidx":
+			if sidx == nil && bsize-hdr <= maxMoovSize {
+				sidx = make([]byte, bsize-hdr)
+				if _, err = io.ReadFull(r, sidx); err != nil {
+					return nil, 0, nil, 0, err
+				}
+				sidxEnd = off + bsize
+			}
+		case "moof":
+			// fragments follow, index is in moov and sidx
+			if moov != nil {
+				return moov, moovEnd, sidx, sidxEnd, nil
+			}
+		}
+		off += bsize
+	}
+	if moov == nil {
+		return nil, 0, nil, 0, errors.New("mp4 has no moov")
+	}
+	return moov, moovEnd, sidx, sidxEnd, nil
+}
+
+func parseMP4Index(r io.ReadSeeker, size int64) (*Index, error) {
+	moov, moovEnd, sidx, sidxEnd, err := readMP4Top(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	idx := new(Index)
+	if mvhd := mp4Child(moov, "mvhd"); mvhd != nil {
+		timescale, duration := mp4Duration(mvhd)
+		if timescale > 0 {
+			idx.Duration = float64(duration) / float64(timescale)
+		}
+	}
+	idx.Fragmented = mp4Child(moov, "mvex") != nil
+
+	if idx.Fragmented {
+		idx.InitSize = moovEnd
+		if sidx != nil {
+			kfs, end, dur, err := parseSidx(sidx, sidxEnd)
+			if err != nil {
+				return nil, err
+			}
+			idx.Keyframes = kfs
+			idx.DataEnd = end
+			if idx.Duration == 0 {
+				idx.Duration = dur
+			}
+		}
+		return idx, nil
+	}
+
+	for _, b := range mp4Boxes(moov) {
+		if b.typ != "trak" {
+			continue
+		}
+		if string(mp4Handler(b.data)) != "vide" {
+			continue
+		}
+		kfs, err := mp4TrakKeyframes(b.data)
+		if err != nil {
+			return nil, err
+		}
+		idx.Keyframes = kfs
+		break
+	}
+	return idx, nil
+}
+
+// mp4Duration parses mvhd or mdhd full box
+func mp4Duration(buf []byte) (timescale uint32, duration uint64) {
+	if len(buf) < 4 {
+		return
+	}
+	if buf[0] == 1 {
+		if len(buf) < 32 {
+			return
+		}
+		return binary.BigEndian.Uint32(buf[20:]), binary.BigEndian.Uint64(buf[24:])
+	}
+	if len(buf) < 20 {
+		return
+	}
+	return binary.BigEndian.Uint32(buf[12:]), uint64(binary.BigEndian.Uint32(buf[16:]))
+}
+
+func mp4Handler(trak []byte) []byte {
+	hdlr := mp4Child(trak, "mdia", "hdlr")
+	if len(hdlr) < 12 {
+		return nil
+	}
+	return hdlr[8:12]
+}
+
+func parseSidx(buf []byte, end int64) ([]Keyframe, int64, float64, error) {
+	if len(buf) < 12 {
+		return nil, 0, 0, errors.New("short sidx")
+	}
+	version := buf[0]
+	timescale := binary.BigEndian.Uint32(buf[8:12])
+	pos := 12
+	var earliest uint64
+	var firstOffset int64
+	if version == 0 {
+		if len(buf) < pos+8 {
+			return nil, 0, 0, errors.New("short sidx")
+		}
+		earliest = uint64(binary.BigEndian.Uint32(buf[pos:]))
+		firstOffset = int64(binary.BigEndian.Uint32(buf[pos+4:]))
+		pos += 8
+	} else {
+		if len(buf) < pos+16 {
+			return nil, 0, 0, errors.New("short sidx")
+		}
+		earliest = binary.BigEndian.Uint64(buf[pos:])
+		firstOffset = int64(binary.BigEndian.Uint64(buf[pos+8:]))
+		pos += 16
+	}
+	if len(buf) < pos+4 || timescale == 0 {
+		return nil, 0, 0, errors.New("wrong sidx")
+	}
+	count := int(binary.BigEndian.Uint16(buf[pos+2:]))
+	pos += 4
+	if len(buf) < pos+count*12 {
+		return nil, 0, 0, errors.New("short sidx")
+	}
+
+	kfs := make([]Keyframe, 0, count)
+	off := end + firstOffset
+	t := earliest
+	for range count {
+		ref := binary.BigEndian.Uint32(buf[pos:])
+		dur := binary.BigEndian.Uint32(buf[pos+4:])
+		if ref>>31 == 1 {
+			return nil, 0, 0, errors.New("hierarchical sidx not supported")
+		}
+		kfs = append(kfs, Keyframe{
+			Offset: off,
+			Time:   float64(t) / float64(timescale),
+		})
+		off += int64(ref & 0x7fffffff)
+		t += uint64(dur)
+		pos += 12
+	}
+	return kfs, off, float64(t-earliest) / float64(timescale), nil
+}
+
+// mp4TrakKeyframes maps sync samples of track to file offsets by sample tables
+func mp4TrakKeyframes(trak []byte) ([]Keyframe, error) {
+	timescale, _ := mp4Duration(mp4Child(trak, "mdia", "mdhd"))
+	stbl := mp4Child(trak, "mdia", "minf", "stbl")
+	if stbl == nil || timescale == 0 {
+		return nil, errors.New("mp4 track has no sample table")
+	}
+
+	stts := mp4Child(stbl, "stts")
+	stsc := mp4Child(stbl, "stsc")
+	stsz := mp4Child(stbl, "stsz")
+	stss := mp4Child(stbl, "stss")
+	var chunks []int64
+	if stco := mp4Child(stbl, "stco"); len(stco) >= 8 {
+		n := int(binary.BigEndian.Uint32(stco[4:]))
+		for i := 0; i < n && 8+i*4+4 <= len(stco); i++ {
+			chunks = append(chunks, int64(binary.BigEndian.Uint32(stco[8+i*4:])))
+		}
+	} else if co64 := mp4Child(stbl, "co64"); len(co64) >= 8 {
+		n := int(binary.BigEndian.Uint32(co64[4:]))
+		for i := 0; i < n && 8+i*8+8 <= len(co64); i++ {
+			chunks = append(chunks, int64(binary.BigEndian.Uint64(co64[8+i*8:])))
+		}
+	}
+	if len(stts) < 8 || len(stsc) < 8 || len(stsz) < 12 || len(chunks) == 0 {
+		return nil, errors.New("mp4 sample table is incomplete")
+	}
+
+	sampleCount := int(binary.BigEndian.Uint32(stsz[8:]))
+	fixedSize := int64(binary.BigEndian.Uint32(stsz[4:]))
+	if fixedSize == 0 {
+		// counts are not trusted, table can't have more entries than box holds
+		sampleCount = min(sampleCount, (len(stsz)-12)/4)
+	}
+	sampleSize := func(i int) int64 {
+		if fixedSize != 0 {
+			return fixedSize
+		}
+		if p := 12 + i*4; p+4 <= len(stsz) {
+			return int64(binary.BigEndian.Uint32(stsz[p:]))
+		}
+		return 0
+	}
+
+	// sync samples, 1-based, all samples are sync without stss
+	var sync map[int]struct{}
+	if len(stss) >= 8 {
+		n := min(int(binary.BigEndian.Uint32(stss[4:])), (len(stss)-8)/4)
+		sync = make(map[int]struct{}, n)
+		for i := 0; i < n && 8+i*4+4 <= len(stss); i++ {
+			sync[int(binary.BigEndian.Uint32(stss[8+i*4:]))] = struct{}{}
+		}
+	}
+
+	// sample times
+	times := make([]uint64, 0, min(sampleCount, (len(stsz)-12)/4))
+	var t uint64
+	sttsN := int(binary.BigEndian.Uint32(stts[4:]))
+	for i := 0; i < sttsN && 8+i*8+8 <= len(stts); i++ {
+		cnt := int(binary.BigEndian.Uint32(stts[8+i*8:]))
+		delta := uint64(binary.BigEndian.Uint32(stts[8+i*8+4:]))
+		for j := 0; j < cnt && len(times) < sampleCount; j++ {
+			times = append(times, t)
+			t += delta
+		}
+	}
+
+	type stscEntry struct{ first, perChunk int }
+	var entries []stscEntry
+	stscN := int(binary.BigEndian.Uint32(stsc[4:]))
+	for i := 0; i < stscN && 8+i*12+12 <= len(stsc); i++ {
+		entries = append(entries, stscEntry{
+			first:    int(binary.BigEndian.Uint32(stsc[8+i*12:])),
+			perChunk: int(binary.BigEndian.Uint32(stsc[8+i*12+4:])),
+		})
+	}
+
+	var kfs []Keyframe
+	sample := 0
+	for e, entry := range entries {
+		last := len(chunks)
+		if e+1 < len(entries) {
+			last = entries[e+1].first - 1
+		}
+		for chunk := entry.first; chunk <= last && chunk-1 < len(chunks); chunk++ {
+			off := chunks[chunk-1]
+			for s := 0; s < entry.perChunk && sample < sampleCount; s++ {
+				_, isSync := sync[sample+1]
+				if (sync == nil || isSync) && sample < len(times) {
+					kfs = append(kfs, Keyframe{
+						Offset: off,
+						Time:   float64(times[sample]) / float64(timescale),
+					})
+				}
+				off += sampleSize(sample)
+				sample++
+			}
+		}
+	}
+	return kfs, nil
+}
+
+var mp4Codecs = map[string]string{
+	"avc1": "h264",
+	"avc3": "h264",
+	"hvc1": "hevc",
+	"hev1": "hevc",
+	"av01": "av1",
+	"vp09": "vp9",
+	"mp4v": "mpeg4",
+	"mp4a": "aac",
+	"ac-3": "ac3",
+	"ec-3": "eac3",
+	"Opus": "opus",
+	"fLaC": "flac",
+	".mp3": "mp3",
+	"dtsc": "dts",
+	"tx3g": "mov_text",
+	"wvtt": "webvtt",
+	"stpp": "ttml",
+}
+
+// mp4Language decodes packed ISO-639-2 language of mdhd
+func mp4Language(mdhd []byte) string {
+	pos := 20
+	if len(mdhd) > 0 && mdhd[0] == 1 {
+		pos = 32
+	}
+	if len(mdhd) < pos+2 {
+		return ""
+	}
+	v := binary.BigEndian.Uint16(mdhd[pos:])
+	lang := []byte{byte(v>>10&0x1F) + 0x60, byte(v>>5&0x1F) + 0x60, byte(v&0x1F) + 0x60}
+	for _, c := range lang {
+		if c < 'a' || c > 'z' {
+			return ""
+		}
+	}
+	return string(lang)
+}
+
+func probeMP4(r io.ReadSeeker, size int64) ([]*Stream, error) {
+	moov, _, _, _, err := readMP4Top(r, size)
+	if err != nil {
+		return nil, err
+	}
+	var streams []*Stream
+	for _, b := range mp4Boxes(moov) {
+		if b.typ != "trak" {
+			continue
+		}
+		var codecType string
+		switch string(mp4Handler(b.data)) {
+		case "vide":
+			codecType = CodecTypeVideo
+		case "soun":
+			codecType = CodecTypeAudio
+		case "sbtl", "subt", "text":
+			codecType = CodecTypeSubtitle
+		default:
+			continue
+		}
+
+		// first sample entry of stsd
+		var fourcc string
+		var entry []byte
+		if stsd := mp4Child(b.data, "mdia", "minf", "stbl", "stsd"); len(stsd) > 8 {
+			if entries := mp4Boxes(stsd[8:]); len(entries) > 0 {
+				fourcc, entry = entries[0].typ, entries[0].data
+			}
+		}
+		s := newStream(codecType, mp4Codecs[fourcc])
+		s.CodecTag = fourcc
+
+		switch codecType {
+		case CodecTypeVideo:
+			// visual sample entry width and height
+			if len(entry) >= 28 {
+				s.Width = int(binary.BigEndian.Uint16(entry[24:]))
+				s.Height = int(binary.BigEndian.Uint16(entry[26:]))
+			}
+		case CodecTypeAudio:
+			if len(entry) >= 28 {
+				s.Channels = int(binary.BigEndian.Uint16(entry[16:]))
+				s.SampleRate = strconv.Itoa(int(binary.BigEndian.Uint32(entry[24:]) >> 16))
+				s.ChannelLayout = channelLayout(s.Channels)
+			}
+		}
+
+		if lang := mp4Language(mp4Child(b.data, "mdia", "mdhd")); lang != "" && lang != "und" {
+			s.Tags["language"] = lang
+		}
+		// track enabled flag of tkhd
+		if tkhd := mp4Child(b.data, "tkhd"); len(tkhd) >= 4 && tkhd[3]&1 == 1 {
+			s.Disposition["default"] = 1
+		}
+		if name := mp4Child(b.data, "udta", "name"); len(name) > 0 {
+			s.Tags["title"] = string(name)
+		}
+		streams = append(streams, s)
+	}
+	return streams, nil
+}
@@ -0,0 +1,69 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func box(typ string, data ...[]byte) []byte {
+	body := bytes.Join(data, nil)
+	buf := binary.BigEndian.AppendUint32(nil, uint32(8+len(body)))
+	buf = append(buf, typ...)
+	return append(buf, body...)
+}
+
+func u32s(v ...uint32) []byte {
+	var buf []byte
+	for _, x := range v {
+		buf = binary.BigEndian.AppendUint32(buf, x)
+	}
+	return buf
+}
+
+// testTrak has 4 samples of 100 bytes in 2 chunks at 1000 and 5000, 1 second each, samples 1 and 3 are sync
+func testTrak(stssCount, stszCount uint32) []byte {
+	mdhd := u32s(0, 0, 0, 1000, 4000, 0)
+	stbl := box("stbl",
+		box("stts", u32s(0, 1, 4, 1000)),
+		box("stsc", u32s(0, 1, 1, 2, 1)),
+		box("stsz", u32s(0, 0, stszCount, 100, 100, 100, 100)),
+		box("stss", u32s(0, stssCount, 1, 3)),
+		box("stco", u32s(0, 2, 1000, 5000)),
+	)
+	return box("mdia", box("mdhd", mdhd), box("minf", stbl))
+}
+
+func TestMP4TrakKeyframes(t *testing.T) {
+	want := []Keyframe{{1000, 0}, {5000, 2}}
+	for _, tc := range []struct {
+		name             string
+		stssCount, count uint32
+	}{
+		{"valid", 2, 4},
+		{"huge counts", 0xFFFFFFFF, 0xFFFFFFFF},
+	} {
+		kfs, err := mp4TrakKeyframes(testTrak(tc.stssCount, tc.count))
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if len(kfs) != len(want) {
+			t.Fatalf("%s: keyframes %v, want %v", tc.name, kfs, want)
+		}
+		for i := range want {
+			if kfs[i] != want[i] {
+				t.Errorf("%s: keyframe %d is %v, want %v", tc.name, i, kfs[i], want[i])
+			}
+		}
+	}
+}
+
+func TestMP4Duration(t *testing.T) {
+	ts, dur := mp4Duration(u32s(0, 0, 0, 90000, 900000))
+	if ts != 90000 || dur != 900000 {
+		t.Errorf("got %d %d", ts, dur)
+	}
+	if ts, _ := mp4Duration([]byte{0, 0}); ts != 0 {
+		t.Error("short box is parsed")
+	}
+}
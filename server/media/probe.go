@@ -0,0 +1,118 @@
+package media
+
+import (
+	"io"
+	"strconv"
+)
+
+// ProbeData follows ffprobe json output (-show_format -show_streams)
+type ProbeData struct {
+	Streams []*Stream `json:"streams"`
+	Format  *Format   `json:"format"`
+}
+
+type Stream struct {
+	Index         int               `json:"index"`
+	CodecName     string            `json:"codec_name,omitempty"`
+	CodecType     string            `json:"codec_type"`
+	CodecTag      string            `json:"codec_tag_string,omitempty"`
+	Width         int               `json:"width,omitempty"`
+	Height        int               `json:"height,omitempty"`
+	SampleRate    string            `json:"sample_rate,omitempty"`
+	Channels      int               `json:"channels,omitempty"`
+	ChannelLayout string            `json:"channel_layout,omitempty"`
+	Disposition   map[string]int    `json:"disposition,omitempty"`
+	Tags          map[string]string `json:"tags,omitempty"`
+}
+
+type Format struct {
+	Filename   string `json:"filename,omitempty"`
+	NBStreams  int    `json:"nb_streams"`
+	FormatName string `json:"format_name"`
+	Duration   string `json:"duration,omitempty"`
+	Size       string `json:"size"`
+	BitRate    string `json:"bit_rate,omitempty"`
+}
+
+const (
+	CodecTypeVideo    = "video"
+	CodecTypeAudio    = "audio"
+	CodecTypeSubtitle = "subtitle"
+)
+
+var formatNames = map[string]string{
+	FormatMKV: "matroska,webm",
+	FormatMP4: "mov,mp4,m4a,3gp,3g2,mj2",
+	FormatTS:  "mpegts",
+}
+
+// Probe reads container headers and reports streams, duration and bitrate
+func Probe(r io.ReadSeeker, size int64, name string) (*ProbeData, error) {
+	idx, err := ParseIndex(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	var streams []*Stream
+	switch idx.Format {
+	case FormatMKV:
+		streams, err = probeMKV(r, size)
+	case FormatMP4:
+		streams, err = probeMP4(r, size)
+	case FormatTS:
+		streams, err = probeTS(r, size)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i, s := range streams {
+		s.Index = i
+	}
+
+	pd := &ProbeData{
+		Streams: streams,
+		Format: &Format{
+			Filename:   name,
+			NBStreams:  len(streams),
+			FormatName: formatNames[idx.Format],
+			Size:       strconv.FormatInt(size, 10),
+			BitRate:    idx.BitRateString(),
+		},
+	}
+	if idx.Duration > 0 {
+		pd.Format.Duration = strconv.FormatFloat(idx.Duration, 'f', 6, 64)
+	}
+	return pd, nil
+}
+
+// Duration returns format duration in seconds
+func (pd *ProbeData) Duration() float64 {
+	if pd.Format == nil {
+		return 0
+	}
+	d, _ := strconv.ParseFloat(pd.Format.Duration, 64)
+	return d
+}
+
+func channelLayout(channels int) string {
+	switch channels {
+	case 1:
+		return "mono"
+	case 2:
+		return "stereo"
+	case 6:
+		return "5.1"
+	case 8:
+		return "7.1"
+	}
+	return ""
+}
+
+func newStream(codecType, codecName string) *Stream {
+	return &Stream{
+		CodecType:   codecType,
+		CodecName:   codecName,
+		Disposition: map[string]int{"default": 0, "forced": 0},
+		Tags:        map[string]string{},
+	}
+}
@@ -0,0 +1,190 @@
+package media
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// Cue is one subtitle event
+type Cue struct {
+	Start time.Duration
+	End   time.Duration
+	Text  string
+}
+
+var (
+	// hours are optional in vtt: 01:02.500 --> 01:04.000
+	srtTimeRe = regexp.MustCompile(`(?:(\d+):)?(\d{2}):(\d{2})[,.](\d{1,3})\s*-->\s*(?:(\d+):)?(\d{2}):(\d{2})[,.](\d{1,3})`)
+	assTagRe  = regexp.MustCompile(`\{[^}]*\}`)
+)
+
+// subText converts subtitle file to utf-8 text,
+// files not in utf-8 are mostly windows-1251 in torrents
+func subText(data []byte) string {
+	data = bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF"))
+	if !utf8.Valid(data) {
+		if dec, err := charmap.Windows1251.NewDecoder().Bytes(data); err == nil {
+			data = dec
+		}
+	}
+	return strings.ReplaceAll(string(data), "\r\n", "\n")
+}
+
+func parseDuration(h, m, s, frac string) time.Duration {
+	hi, _ := strconv.Atoi(h)
+	mi, _ := strconv.Atoi(m)
+	si, _ := strconv.Atoi(s)
+	// fraction is milliseconds in srt and centiseconds in ass
+	for len(frac) < 3 {
+		frac += "0"
+	}
+	ms, _ := strconv.Atoi(frac)
+	return time.Duration(hi)*time.Hour + time.Duration(mi)*time.Minute + time.Duration(si)*time.Second + time.Duration(ms)*time.Millisecond
+}
+
+// ParseSRT parses SubRip subtitles, also accepts WebVTT cues
+func ParseSRT(data []byte) []Cue {
+	var cues []Cue
+	var cue *Cue
+	var text []string
+	skip := false // vtt header, note, style and region blocks
+	flush := func() {
+		if cue != nil {
+			cue.Text = strings.TrimSpace(strings.Join(text, "\n"))
+			if cue.Text != "" {
+				cues = append(cues, *cue)
+			}
+		}
+		cue, text = nil, nil
+	}
+	sc := bufio.NewScanner(strings.NewReader(subText(data)))
+	sc.Buffer(make([]byte, 64<<10), 1<<20)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), " \t")
+		if skip {
+			skip = line != ""
+			continue
+		}
+		if cue == nil && isVTTBlock(line) {
+			flush()
+			skip = true
+			continue
+		}
+		if m := srtTimeRe.FindStringSubmatch(line); m != nil {
+			flush()
+			cue = &Cue{
+				Start: parseDuration(m[1], m[2], m[3], m[4]),
+				End:   parseDuration(m[5], m[6], m[7], m[8]),
+			}
+			continue
+		}
+		if line == "" {
+			flush()
+			continue
+		}
+		if cue != nil {
+			text = append(text, assTagRe.ReplaceAllString(line, ""))
+		}
+	}
+	flush()
+	return cues
+}
+
+func isVTTBlock(line string) bool {
+	for _, kw := range []string{"WEBVTT", "NOTE", "STYLE", "REGION"} {
+		if rest, ok := strings.CutPrefix(line, kw); ok && (rest == "" || rest[0] == ' ' || rest[0] == '\t') {
+			return true
+		}
+	}
+	return false
+}
+
+// assText strips override tags of ass dialogue text
+func assText(text string) string {
+	text = assTagRe.ReplaceAllString(text, "")
+	text = strings.NewReplacer(`\N`, "\n", `\n`, "\n", `\h`, " ").Replace(text)
+	return strings.TrimSpace(text)
+}
+
+// ParseASS parses dialogue events of ASS/SSA subtitles
+func ParseASS(data []byte) []Cue {
+	var cues []Cue
+	// default field order of [Events] section
+	format := []string{"layer", "start", "end", "style", "name", "marginl", "marginr", "marginv", "effect", "text"}
+	inEvents := false
+	for line := range strings.SplitSeq(subText(data), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			inEvents = strings.EqualFold(line, "[Events]")
+			continue
+		}
+		if !inEvents {
+			continue
+		}
+		key, val, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(key) {
+		case "format":
+			format = format[:0]
+			for f := range strings.SplitSeq(val, ",") {
+				format = append(format, strings.ToLower(strings.TrimSpace(f)))
+			}
+		case "dialogue":
+			fields := strings.SplitN(strings.TrimSpace(val), ",", len(format))
+			if len(fields) < len(format) {
+				continue
+			}
+			cue := Cue{}
+			for i, f := range format {
+				switch f {
+				case "start":
+					cue.Start = parseASSTime(fields[i])
+				case "end":
+					cue.End = parseASSTime(fields[i])
+				case "text":
+					cue.Text = assText(fields[i])
+				}
+			}
+			if cue.Text != "" {
+				cues = append(cues, cue)
+			}
+		}
+	}
+	return cues
+}
+
+func parseASSTime(s string) time.Duration {
+	h, rest, _ := strings.Cut(strings.TrimSpace(s), ":")
+	m, rest, _ := strings.Cut(rest, ":")
+	sec, frac, _ := strings.Cut(rest, ".")
+	return parseDuration(h, m, sec, frac)
+}
+
+func vttTime(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// WriteVTT writes cues as WebVTT document
+func WriteVTT(w io.Writer, cues []Cue) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("WEBVTT\n\n")
+	for _, c := range cues {
+		// blank lines end cue in vtt
+		text := strings.ReplaceAll(c.Text, "\n\n", "\n")
+		text = strings.ReplaceAll(text, "-->", "->")
+		fmt.Fprintf(bw, "%s --> %s\n%s\n\n", vttTime(c.Start), vttTime(c.End), text)
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,97 @@
+package media
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+func ms(v int) time.Duration {
+	return time.Duration(v) * time.Millisecond
+}
+
+func TestParseSRT(t *testing.T) {
+	data := "\xEF\xBB\xBF1\r\n00:00:01,000 --> 00:00:02,500\r\n<i>Hello</i>\r\nworld\r\n\r\n" +
+		"2\r\n01:02:03,040 --> 01:02:04,000\r\n{\\an8}Top\r\n\r\n" +
+		"3\r\n00:00:05,000 --> 00:00:06,000\r\n\r\n"
+	want := []Cue{
+		{ms(1000), ms(2500), "<i>Hello</i>\nworld"},
+		{time.Hour + 2*time.Minute + ms(3040), time.Hour + 2*time.Minute + ms(4000), "Top"},
+	}
+	if got := ParseSRT([]byte(data)); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestParseVTT(t *testing.T) {
+	data := `WEBVTT - movie
+
+NOTE
+timing of cues was fixed by hand
+
+STYLE
+::cue { color: yellow }
+
+00:01.000 --> 00:02.000 align:start
+Short timing
+
+intro
+01:00:00.000 --> 01:00:01.500
+Long timing
+
+NOTE single line comment
+
+02:03.250 --> 02:04.000
+Last
+`
+	want := []Cue{
+		{ms(1000), ms(2000), "Short timing"},
+		{time.Hour, time.Hour + ms(1500), "Long timing"},
+		{2*time.Minute + ms(3250), 2*time.Minute + ms(4000), "Last"},
+	}
+	if got := ParseSRT([]byte(data)); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestParseSRTWindows1251(t *testing.T) {
+	text, err := charmap.Windows1251.NewEncoder().String("Привет")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cues := ParseSRT([]byte("1\n00:00:01,000 --> 00:00:02,000\n" + text + "\n"))
+	if len(cues) != 1 || cues[0].Text != "Привет" {
+		t.Errorf("got %+v", cues)
+	}
+}
+
+func TestParseASS(t *testing.T) {
+	data := `[Script Info]
+Title: test
+
+[Events]
+Format: Layer, Start, End, Style, Text
+Dialogue: 0,0:00:01.50,0:00:03.00,Default,{\i1}Hello\Nworld, again
+Comment: 0,0:00:04.00,0:00:05.00,Default,hidden
+Dialogue: 0,0:00:06.00,0:00:07.00,Default,{\pos(1,1)}
+`
+	want := []Cue{{ms(1500), ms(3000), "Hello\nworld, again"}}
+	if got := ParseASS([]byte(data)); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestWriteVTT(t *testing.T) {
+	var buf bytes.Buffer
+	cues := []Cue{{time.Hour + ms(61001), time.Hour + ms(62000), "a --> b\n\nc"}}
+	if err := WriteVTT(&buf, cues); err != nil {
+		t.Fatal(err)
+	}
+	want := "WEBVTT\n\n01:01:01.001 --> 01:01:02.000\na -> b\nc\n\n"
+	if buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,369 @@
+package media
+
+import (
+	"errors"
+	"io"
+)
+
+const (
+	tsSyncByte   = 0x47
+	tsProbeSize  = 1 << 20
+	tsScanChunk  = 348 * 188 // packets read at once by keyframe scan
+	pcrClock     = 27000000
+	pcrWrapValue = (1 << 33) * 300
+)
+
+// tsStream keeps what is needed to locate keyframes of transport stream on demand
+type tsStream struct {
+	packetLen int
+	videoPid  uint16
+	codec     string
+	pcrPid    uint16
+	firstPCR  int64
+}
+
+// tsPacketLen returns 188 for transport stream, 192 for m2ts with timecode prefix
+func tsPacketLen(buf []byte) int {
+	for _, l := range []int{188, 192} {
+		start := l - 188
+		if len(buf) > start+l && buf[start] == tsSyncByte && buf[start+l] == tsSyncByte {
+			return l
+		}
+	}
+	return 0
+}
+
+// tsPCR returns pid and program clock reference of packet if present
+func tsPCR(pkt []byte) (pid uint16, pcr int64, ok bool) {
+	if len(pkt) < 12 || pkt[0] != tsSyncByte {
+		return
+	}
+	pid = uint16(pkt[1]&0x1F)<<8 | uint16(pkt[2])
+	if pkt[3]&0x20 == 0 || pkt[4] < 7 || pkt[5]&0x10 == 0 {
+		return
+	}
+	base := int64(pkt[6])<<25 | int64(pkt[7])<<17 | int64(pkt[8])<<9 | int64(pkt[9])<<1 | int64(pkt[10])>>7
+	ext := int64(pkt[10]&1)<<8 | int64(pkt[11])
+	return pid, base*300 + ext, true
+}
+
+func readTSWindow(r io.ReadSeeker, off, size int64) ([]byte, error) {
+	l := min(int64(tsProbeSize), size-off)
+	buf := make([]byte, l)
+	if err := readAt(r, off, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// parseTSIndex calculates duration by first and last PCR.
+// Keyframes are not indexed, as it would read whole file, TSKeyframe finds them on demand
+func parseTSIndex(r io.ReadSeeker, size int64) (*Index, error) {
+	head, err := readTSWindow(r, 0, size)
+	if err != nil {
+		return nil, err
+	}
+	plen := tsPacketLen(head)
+	if plen == 0 {
+		return nil, errors.New("not mpeg-ts")
+	}
+	prefix := plen - 188
+
+	var pcrPid uint16
+	var first int64 = -1
+	for off := 0; off+plen <= len(head); off += plen {
+		if pid, pcr, ok := tsPCR(head[off+prefix : off+plen]); ok {
+			pcrPid, first = pid, pcr
+			break
+		}
+	}
+	idx := new(Index)
+	if first < 0 {
+		return idx, nil
+	}
+	if pmt := tsFindPMT(head, plen); pmt != nil {
+		if pid, codec := tsVideoPid(pmt); codec != "" {
+			idx.ts = &tsStream{packetLen: plen, videoPid: pid, codec: codec, pcrPid: pcrPid, firstPCR: first}
+		}
+	}
+
+	// align tail window to packets from file start
+	tailOff := max(size-tsProbeSize, 0)
+	tailOff -= tailOff % int64(plen)
+	tail, err := readTSWindow(r, tailOff, size)
+	if err != nil {
+		return nil, err
+	}
+	var last int64 = -1
+	for off := 0; off+plen <= len(tail); off += plen {
+		if pid, pcr, ok := tsPCR(tail[off+prefix : off+plen]); ok && pid == pcrPid {
+			last = pcr
+		}
+	}
+	if last < 0 {
+		return idx, nil
+	}
+	if last < first {
+		last += pcrWrapValue
+	}
+	idx.Duration = float64(last-first) / pcrClock
+	return idx, nil
+}
+
+// TSKeyframe returns first keyframe of video stream in [off, end) of transport stream,
+// time is taken from program clock reference nearest to keyframe
+func (i *Index) TSKeyframe(r io.ReadSeeker, off, end int64) (kf Keyframe, ok bool, err error) {
+	ts := i.ts
+	if ts == nil {
+		return kf, false, nil
+	}
+	plen := int64(ts.packetLen)
+	prefix := plen - 188
+	if rem := off % plen; rem != 0 {
+		off += plen - rem
+	}
+	end = min(end, i.Size)
+
+	pcr := int64(-1)
+	kf.Offset = -1
+	buf := make([]byte, tsScanChunk/188*plen)
+	for ; off+plen <= end; off += int64(len(buf)) {
+		n := min(int64(len(buf)), (end-off)/plen*plen)
+		if err = readAt(r, off, buf[:n]); err != nil {
+			return kf, false, err
+		}
+		for p := int64(0); p+plen <= n; p += plen {
+			pkt := buf[p+prefix : p+plen]
+			if pid, v, has := tsPCR(pkt); has && pid == ts.pcrPid {
+				pcr = v
+				if kf.Offset >= 0 {
+					break
+				}
+			}
+			if kf.Offset < 0 && tsIsKeyframe(pkt, ts.videoPid, ts.codec) {
+				kf.Offset = off + p
+				if pcr >= 0 {
+					break
+				}
+			}
+		}
+		if kf.Offset >= 0 && pcr >= 0 {
+			break
+		}
+	}
+	if kf.Offset < 0 {
+		return kf, false, nil
+	}
+	if pcr >= 0 {
+		if pcr < ts.firstPCR {
+			pcr += pcrWrapValue
+		}
+		kf.Time = float64(pcr-ts.firstPCR) / pcrClock
+	}
+	return kf, true, nil
+}
+
+// tsIsKeyframe reports whether packet starts random access point of video pid,
+// by random access indicator or by start codes of stream without one
+func tsIsKeyframe(pkt []byte, pid uint16, codec string) bool {
+	if len(pkt) < 188 || pkt[0] != tsSyncByte || pkt[1]&0x40 == 0 {
+		return false
+	}
+	if uint16(pkt[1]&0x1F)<<8|uint16(pkt[2]) != pid {
+		return false
+	}
+	pos := 4
+	if pkt[3]&0x20 != 0 {
+		if pkt[4] > 0 && pkt[5]&0x40 != 0 {
+			return true
+		}
+		pos += 1 + int(pkt[4])
+	}
+	// pes header
+	if pos+9 > len(pkt) || pkt[pos] != 0 || pkt[pos+1] != 0 || pkt[pos+2] != 1 {
+		return false
+	}
+	pos += 9 + int(pkt[pos+8])
+	for ; pos+3 < len(pkt); pos++ {
+		if pkt[pos] != 0 || pkt[pos+1] != 0 || pkt[pos+2] != 1 {
+			continue
+		}
+		nal := pkt[pos+3]
+		switch codec {
+		case "h264":
+			// idr, sps
+			if t := nal & 0x1F; t == 5 || t == 7 {
+				return true
+			}
+		case "hevc":
+			// irap, vps, sps
+			if t := nal >> 1 & 0x3F; t >= 16 && t <= 21 || t == 32 || t == 33 {
+				return true
+			}
+		case "mpeg1video", "mpeg2video":
+			// sequence header, group of pictures
+			if nal == 0xB3 || nal == 0xB8 {
+				return true
+			}
+		case "mpeg4":
+			// visual object sequence, group of vop
+			if nal == 0xB0 || nal == 0xB3 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type tsCodec struct{ codecType, codecName string }
+
+var tsStreamTypes = map[byte]tsCodec{
+	0x01: {CodecTypeVideo, "mpeg1video"},
+	0x02: {CodecTypeVideo, "mpeg2video"},
+	0x10: {CodecTypeVideo, "mpeg4"},
+	0x1B: {CodecTypeVideo, "h264"},
+	0x24: {CodecTypeVideo, "hevc"},
+	0x03: {CodecTypeAudio, "mp2"},
+	0x04: {CodecTypeAudio, "mp3"},
+	0x0F: {CodecTypeAudio, "aac"},
+	0x11: {CodecTypeAudio, "aac_latm"},
+	0x81: {CodecTypeAudio, "ac3"},
+	0x82: {CodecTypeAudio, "dts"},
+	0x87: {CodecTypeAudio, "eac3"},
+	0x90: {CodecTypeSubtitle, "hdmv_pgs_subtitle"},
+}
+
+// tsSection returns psi section payload of packet with given pid starting in packet
+func tsSection(pkt []byte, pid uint16) []byte {
+	if len(pkt) < 188 || pkt[0] != tsSyncByte || pkt[1]&0x40 == 0 {
+		return nil
+	}
+	if uint16(pkt[1]&0x1F)<<8|uint16(pkt[2]) != pid {
+		return nil
+	}
+	pos := 4
+	if pkt[3]&0x20 != 0 {
+		pos += 1 + int(pkt[4])
+	}
+	if pos >= len(pkt) {
+		return nil
+	}
+	pos += 1 + int(pkt[pos]) // pointer field
+	if pos+3 > len(pkt) {
+		return nil
+	}
+	l := int(pkt[pos+1]&0x0F)<<8 | int(pkt[pos+2])
+	end := min(pos+3+l, len(pkt))
+	return pkt[pos:end]
+}
+
+// probeTS reads streams from first program map table
+func probeTS(r io.ReadSeeker, size int64) ([]*Stream, error) {
+	head, err := readTSWindow(r, 0, size)
+	if err != nil {
+		return nil, err
+	}
+	plen := tsPacketLen(head)
+	if plen == 0 {
+		return nil, errors.New("not mpeg-ts")
+	}
+
+	pmt := tsFindPMT(head, plen)
+	if pmt == nil {
+		return nil, errors.New("mpeg-ts has no program map table")
+	}
+	return parseTSPMT(pmt), nil
+}
+
+// tsFindPMT returns first program map table section in buffer
+func tsFindPMT(head []byte, plen int) []byte {
+	prefix := plen - 188
+	pmtPid := uint16(0xFFFF)
+	for off := 0; off+plen <= len(head); off += plen {
+		pkt := head[off+prefix : off+plen]
+		if pmtPid == 0xFFFF {
+			// program association table
+			sec := tsSection(pkt, 0)
+			if len(sec) < 12 || sec[0] != 0x00 {
+				continue
+			}
+			for p := 8; p+4 <= len(sec)-4; p += 4 {
+				prog := uint16(sec[p])<<8 | uint16(sec[p+1])
+				if prog != 0 {
+					pmtPid = uint16(sec[p+2]&0x1F)<<8 | uint16(sec[p+3])
+					break
+				}
+			}
+			continue
+		}
+		sec := tsSection(pkt, pmtPid)
+		if len(sec) < 16 || sec[0] != 0x02 {
+			continue
+		}
+		return sec
+	}
+	return nil
+}
+
+// tsVideoPid returns pid and codec of first video stream of program map table
+func tsVideoPid(sec []byte) (uint16, string) {
+	end := len(sec) - 4 // crc
+	pos := 12 + (int(sec[10]&0x0F)<<8 | int(sec[11]))
+	for pos+5 <= end {
+		st, ok := tsStreamTypes[sec[pos]]
+		if ok && st.codecType == CodecTypeVideo {
+			return uint16(sec[pos+1]&0x1F)<<8 | uint16(sec[pos+2]), st.codecName
+		}
+		pos += 5 + (int(sec[pos+3]&0x0F)<<8 | int(sec[pos+4]))
+	}
+	return 0, ""
+}
+
+func parseTSPMT(sec []byte) []*Stream {
+	var streams []*Stream
+	end := len(sec) - 4 // crc
+	pos := 12 + (int(sec[10]&0x0F)<<8 | int(sec[11]))
+	for pos+5 <= end {
+		streamType := sec[pos]
+		esLen := int(sec[pos+3]&0x0F)<<8 | int(sec[pos+4])
+		descs := sec[pos+5 : min(pos+5+esLen, end)]
+		pos += 5 + esLen
+
+		st, ok := tsStreamTypes[streamType]
+		var lang string
+		for d := 0; d+2 <= len(descs); {
+			tag, l := descs[d], int(descs[d+1])
+			body := descs[d+2 : min(d+2+l, len(descs))]
+			switch tag {
+			case 0x0A: // ISO 639 language
+				if len(body) >= 3 {
+					lang = string(body[:3])
+				}
+			case 0x6A: // DVB AC-3
+				st, ok = tsCodec{CodecTypeAudio, "ac3"}, true
+			case 0x7A: // DVB enhanced AC-3
+				st, ok = tsCodec{CodecTypeAudio, "eac3"}, true
+			case 0x59: // DVB subtitling
+				st, ok = tsCodec{CodecTypeSubtitle, "dvb_subtitle"}, true
+				if len(body) >= 3 {
+					lang = string(body[:3])
+				}
+			case 0x56: // teletext
+				st, ok = tsCodec{CodecTypeSubtitle, "dvb_teletext"}, true
+				if len(body) >= 3 {
+					lang = string(body[:3])
+				}
+			}
+			d += 2 + l
+		}
+		if !ok {
+			continue
+		}
+		s := newStream(st.codecType, st.codecName)
+		if lang != "" && lang != "und" {
+			s.Tags["language"] = lang
+		}
+		streams = append(streams, s)
+	}
+	return streams
+}
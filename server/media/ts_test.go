@@ -0,0 +1,114 @@
+package media
+
+import (
+	"bytes"
+	"testing"
+)
+
+func tsPacket(pid uint16, start bool, af, payload []byte) []byte {
+	pkt := bytes.Repeat([]byte{0xFF}, 188)
+	pkt[0] = tsSyncByte
+	pkt[1] = byte(pid>>8) & 0x1F
+	if start {
+		pkt[1] |= 0x40
+	}
+	pkt[2] = byte(pid)
+	pkt[3] = 0x10
+	pos := 4
+	if af != nil {
+		pkt[3] |= 0x20
+		pkt[4] = byte(len(af))
+		pos += 1 + copy(pkt[5:], af)
+	}
+	copy(pkt[pos:], payload)
+	return pkt
+}
+
+// tsPCRField is adaptation field with pcr of seconds and flags
+func tsPCRField(sec int64, flags byte) []byte {
+	base := sec * 90000
+	return []byte{flags | 0x10, byte(base >> 25), byte(base >> 17), byte(base >> 9), byte(base >> 1), byte(base&1)<<7 | 0x7E, 0}
+}
+
+func tsPES(es ...byte) []byte {
+	return append([]byte{0, 0, 1, 0xE0, 0, 0, 0x80, 0, 0}, es...)
+}
+
+// testTS has h264 video with pcr on pid 0x100 and aac audio, pcr runs from 1 to 6 second,
+// keyframes are at packet 2 by random access indicator and at packet 11 by sps
+func testTS() []byte {
+	pat := []byte{0, 0x00, 0xB0, 13, 0, 1, 0xC1, 0, 0, 0, 1, 0xF0, 0x00, 0, 0, 0, 0}
+	pmt := []byte{0, 0x02, 0xB0, 23, 0, 1, 0xC1, 0, 0, 0xE1, 0x00, 0xF0, 0,
+		0x1B, 0xE1, 0x00, 0xF0, 0, 0x0F, 0xE1, 0x01, 0xF0, 0, 0, 0, 0, 0}
+	var pkts [][]byte
+	pkts = append(pkts, tsPacket(0, true, nil, pat), tsPacket(0x1000, true, nil, pmt))
+	pkts = append(pkts, tsPacket(0x100, true, tsPCRField(1, 0x40), tsPES(0, 0, 1, 0x65)))
+	for range 7 {
+		pkts = append(pkts, tsPacket(0x100, false, nil, nil))
+	}
+	pkts = append(pkts, tsPacket(0x100, false, tsPCRField(3, 0), nil))
+	pkts = append(pkts, tsPacket(0x100, true, nil, tsPES(0, 0, 0, 1, 0x09, 0xF0, 0, 0, 0, 1, 0x67)))
+	pkts = append(pkts, tsPacket(0x100, true, nil, tsPES(0, 0, 0, 1, 0x41)))
+	pkts = append(pkts, tsPacket(0x100, false, tsPCRField(6, 0), nil))
+	return bytes.Join(pkts, nil)
+}
+
+func TestParseTSIndex(t *testing.T) {
+	data := testTS()
+	idx, err := ParseIndex(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idx.Format != FormatTS || idx.Duration != 5 {
+		t.Errorf("format %q duration %v, want mpegts 5", idx.Format, idx.Duration)
+	}
+	if idx.ts == nil || idx.ts.videoPid != 0x100 || idx.ts.codec != "h264" {
+		t.Fatalf("video stream is not found: %+v", idx.ts)
+	}
+
+	r := bytes.NewReader(data)
+	for _, tc := range []struct {
+		off    int64
+		ok     bool
+		want   Keyframe
+		reason string
+	}{
+		{0, true, Keyframe{2 * 188, 0}, "random access indicator"},
+		{3*188 + 1, true, Keyframe{11 * 188, 2}, "sps"},
+		{12 * 188, false, Keyframe{}, "non idr slice"},
+	} {
+		kf, ok, err := idx.TSKeyframe(r, tc.off, int64(len(data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != tc.ok || ok && kf != tc.want {
+			t.Errorf("%s: keyframe %v %v, want %v %v", tc.reason, kf, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestProbeTS(t *testing.T) {
+	data := testTS()
+	streams, err := probeTS(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(streams) != 2 || streams[0].CodecName != "h264" || streams[1].CodecName != "aac" {
+		t.Errorf("wrong streams %v", streams)
+	}
+}
+
+func TestTSPacketLen(t *testing.T) {
+	data := testTS()
+	if l := tsPacketLen(data); l != 188 {
+		t.Errorf("packet len %d, want 188", l)
+	}
+	m2ts := make([]byte, 0, len(data)/188*192)
+	for p := 0; p < len(data); p += 188 {
+		m2ts = append(m2ts, 0, 0, 0, 0)
+		m2ts = append(m2ts, data[p:p+188]...)
+	}
+	if l := tsPacketLen(m2ts); l != 192 {
+		t.Errorf("m2ts packet len %d, want 192", l)
+	}
+}
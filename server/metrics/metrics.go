@@ -0,0 +1,136 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Writer writes metrics in prometheus text exposition format.
+// Samples of one metric must be written together, help and type are written once per name.
+type Writer struct {
+	w     *bufio.Writer
+	names map[string]struct{}
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		w:     bufio.NewWriter(w),
+		names: make(map[string]struct{}),
+	}
+}
+
+// Flush writes buffered metrics to underlying writer
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
+
+// Gauge writes gauge sample, labels are name and value pairs
+func (w *Writer) Gauge(name, help string, value float64, labels ...string) {
+	w.header(name, help, "gauge")
+	w.sample(name, value, labels)
+}
+
+// Counter writes counter sample, labels are name and value pairs
+func (w *Writer) Counter(name, help string, value float64, labels ...string) {
+	w.header(name, help, "counter")
+	w.sample(name, value, labels)
+}
+
+// Histogram writes buckets, sum and count of histogram
+func (w *Writer) Histogram(name, help string, h *Histogram, labels ...string) {
+	w.header(name, help, "histogram")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var cum uint64
+	for i, b := range h.buckets {
+		cum += h.counts[i]
+		w.sample(name+"_bucket", float64(cum), append(labels, "le", formatFloat(b)))
+	}
+	w.sample(name+"_bucket", float64(h.count), append(labels, "le", "+Inf"))
+	w.sample(name+"_sum", h.sum, labels)
+	w.sample(name+"_count", float64(h.count), labels)
+}
+
+func (w *Writer) header(name, help, typ string) {
+	if _, ok := w.names[name]; ok {
+		return
+	}
+	w.names[name] = struct{}{}
+	w.w.WriteString("# HELP " + name + " " + escapeHelp(help) + "\n")
+	w.w.WriteString("# TYPE " + name + " " + typ + "\n")
+}
+
+func (w *Writer) sample(name string, value float64, labels []string) {
+	w.w.WriteString(name)
+	if len(labels) > 1 {
+		w.w.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				w.w.WriteByte(',')
+			}
+			w.w.WriteString(labels[i] + `="` + escapeLabel(labels[i+1]) + `"`)
+		}
+		w.w.WriteByte('}')
+	}
+	w.w.WriteByte(' ')
+	w.w.WriteString(formatFloat(value))
+	w.w.WriteByte('\n')
+}
+
+var (
+	helpReplacer  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return labelReplacer.Replace(s)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Histogram counts observations in buckets by upper bound
+type Histogram struct {
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+	mu      sync.Mutex
+}
+
+func NewHistogram(buckets ...float64) *Histogram {
+	sort.Float64s(buckets)
+	return &Histogram{
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)),
+	}
+}
+
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	i := sort.SearchFloat64s(h.buckets, v)
+	if i < len(h.buckets) {
+		h.counts[i]++
+	}
+	h.sum += v
+	h.count++
+}
@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	activeStreams atomic.Int64
+	streamsTotal  atomic.Int64
+	streamBytes   atomic.Int64
+
+	// stream durations in seconds, from seek of few seconds to whole movie
+	streamDuration = NewHistogram(1, 10, 60, 300, 1800, 3600, 7200)
+)
+
+// StreamWriter counts bytes sent to client of http stream
+type StreamWriter struct {
+	http.ResponseWriter
+	start  time.Time
+	status int
+	offset int64 // file offset of body, -1 for multipart ranges
+	sent   int64
+	err    error
+}
+
+// StartStream registers new http stream, End must be called when it is done
+func StartStream(w http.ResponseWriter) *StreamWriter {
+	activeStreams.Add(1)
+	streamsTotal.Add(1)
+	return &StreamWriter{ResponseWriter: w, start: time.Now()}
+}
+
+func (s *StreamWriter) WriteHeader(status int) {
+	s.status = status
+	if status == http.StatusPartialContent {
+		// single range has Content-Range, multipart one has ranges in body
+		var end, size int64
+		if _, err := fmt.Sscanf(s.Header().Get("Content-Range"), "bytes %d-%d/%d", &s.offset, &end, &size); err != nil {
+			s.offset = -1
+		}
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// SetOffset sets file offset of body written without range request
+func (s *StreamWriter) SetOffset(offset int64) {
+	s.offset = offset
+}
+
+func (s *StreamWriter) Write(p []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(p)
+	s.sent += int64(n)
+	streamBytes.Add(int64(n))
+	if err != nil && s.err == nil {
+		s.err = err
+	}
+	return n, err
+}
+
+// Status returns response status, 200 if only body was written
+func (s *StreamWriter) Status() int {
+	if s.status == 0 {
+		return http.StatusOK
+	}
+	return s.status
+}
+
+// Sent returns bytes of body sent to client
+func (s *StreamWriter) Sent() int64 {
+	return s.sent
+}
+
+// Served returns file byte range [from, to) sent to client, -1 if body has multipart ranges
+func (s *StreamWriter) Served() (from, to int64) {
+	if s.offset < 0 {
+		return -1, -1
+	}
+	return s.offset, s.offset + s.sent
+}
+
+// Err returns first write error
+func (s *StreamWriter) Err() error {
+	return s.err
+}
+
+// Started returns start time of stream
+func (s *StreamWriter) Started() time.Time {
+	return s.start
+}
+
+// End unregisters stream and observes its duration
+func (s *StreamWriter) End() {
+	activeStreams.Add(-1)
+	streamDuration.Observe(time.Since(s.start).Seconds())
+}
+
+// WriteStreams writes http stream metrics
+func WriteStreams(w *Writer) {
+	w.Gauge("torrserver_streams_active", "Number of active http streams.", float64(activeStreams.Load()))
+	w.Counter("torrserver_streams_total", "Total number of http streams.", float64(streamsTotal.Load()))
+	w.Counter("torrserver_stream_sent_bytes_total", "Total bytes sent to http stream clients.", float64(streamBytes.Load()))
+	w.Histogram("torrserver_stream_duration_seconds", "Duration of finished http streams.", streamDuration)
+}
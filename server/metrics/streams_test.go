@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStreamWriterServed(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 100)
+	for _, tc := range []struct {
+		rng      string
+		from, to int64
+	}{
+		{"", 0, 1000},
+		{"bytes=100-199", 100, 200},
+		{"bytes=900-", 900, 1000},
+		{"bytes=-10", 990, 1000},
+		{"bytes=0-9,20-29", -1, -1},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/stream", nil)
+		if tc.rng != "" {
+			req.Header.Set("Range", tc.rng)
+		}
+		sw := StartStream(httptest.NewRecorder())
+		http.ServeContent(sw, req, "a.mkv", time.Time{}, bytes.NewReader(content))
+		sw.End()
+		if from, to := sw.Served(); from != tc.from || to != tc.to {
+			t.Errorf("range %q: served [%d, %d), want [%d, %d)", tc.rng, from, to, tc.from, tc.to)
+		}
+	}
+
+	sw := StartStream(httptest.NewRecorder())
+	sw.SetOffset(4096)
+	sw.Write(content[:100])
+	sw.End()
+	if from, to := sw.Served(); from != 4096 || to != 4196 {
+		t.Errorf("segment served [%d, %d)", from, to)
+	}
+}
@@ -87,6 +87,29 @@ func (mt mimeType) IsSub() bool {
 	return strings.HasPrefix(string(mt), "text/srt") || strings.HasPrefix(string(mt), "text/smi") || strings.HasPrefix(string(mt), "text/ssa")
 }
 
+// DLNA flags: streaming transfer mode, background transfer mode, connection stalling, DLNA v1.5
+const (
+	dlnaStreamingFlags   = "01700000000000000000000000000000"
+	dlnaInteractiveFlags = "00d00000000000000000000000000000"
+)
+
+// TransferMode returns value of transferMode.dlna.org header
+func (mt mimeType) TransferMode() string {
+	if mt.IsImage() || mt.IsSub() {
+		return "Interactive"
+	}
+	return "Streaming"
+}
+
+// ContentFeatures returns value of contentFeatures.dlna.org header: byte seek supported, not transcoded
+func (mt mimeType) ContentFeatures() string {
+	flags := dlnaStreamingFlags
+	if mt.TransferMode() == "Interactive" {
+		flags = dlnaInteractiveFlags
+	}
+	return "DLNA.ORG_OP=01;DLNA.ORG_CI=0;DLNA.ORG_FLAGS=" + flags
+}
+
 // Returns the group "type", the part before the '/'.
 func (mt mimeType) Type() string {
 	return strings.SplitN(string(mt), "/", 2)[0]
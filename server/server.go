@@ -1,7 +1,12 @@
 package server
 
 import (
+	"context"
+	"time"
+
+	"server/dlna"
 	"server/settings"
+	"server/torr"
 	"server/web"
 )
 
@@ -19,7 +24,17 @@ func WaitServer() string {
 	return ""
 }
 
-func Stop() {
-	web.Stop()
+// Stop shuts down web server gracefully, active streams are given timeout to finish
+func Stop(timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	web.Stop(ctx)
 	settings.CloseDB()
 }
+
+// Reload rereads settings from DB and applies them to running torrent client,
+// client is reconnected only if changed settings need it, media server follows its settings
+func Reload() {
+	torr.ReloadSettings()
+	dlna.Apply()
+}
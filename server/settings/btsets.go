@@ -2,21 +2,29 @@ package settings
 
 import (
 	"encoding/json"
-
-	"log"
 )
 
 type BTSets struct {
 	// Cache
-	CacheSize       int64 // in byte, def 64 MB
+	CacheSize       int64 // in byte, def 64 MB, shared by all torrents
 	ReaderReadAHead int   // in percent, 5%-100%, [...S__X__E...] [S-E] not clean
 	PreloadCache    int   // in percent
 
+	// Disk
+	UseDisk           bool   // keep complete pieces on disk between opens
+	TorrentsSavePath  string // dir for disk pieces, def <Path>/cache
+	DiskCacheSize     int64  // in byte, def 1 GB
+	RemoveCacheOnDrop bool   // remove disk pieces of torrent on drop
+
 	// Torrent
 	ForceEncrypt             bool
 	RetrackersMode           int  // 0 - don`t add, 1 - add retrackers (def), 2 - remove retrackers 3 - replace retrackers
 	TorrentDisconnectTimeout int  // in seconds
-	EnableDebug              bool // debug logs
+	EnableDebug              bool // debug logs of subsystems without own level
+
+	// Log
+	LogLevels map[string]string // level by subsystem: main, settings, torr, torrstor, web, dlna, search; debug, info, warn, error
+	LogFile   bool              // write log to rotating torrserver.log in config dir
 
 	// BT Config
 	EnableIPv6        bool
@@ -31,11 +39,44 @@ type BTSets struct {
 	ConnectionsLimit  int
 	PeersListenPort   int
 
+	// Schedule
+	BandwidthProfiles []*BandwidthProfile // named limits
+	BandwidthSchedule []*BandwidthRule    // first matching rule activates its profile, settings limits out of schedule
+
 	// Reader
 	ResponsiveMode bool // enable Responsive reader (don't wait pieceComplete)
+
+	// DLNA
+	EnableDLNA   bool   // upnp media server for lan devices
+	FriendlyName string // name of media server, def TorrServer on <hostname>
+
+	// Search
+	TorznabUrls []*TorznabConfig // torznab endpoints, e.g. jackett or prowlarr indexers
+
+	// Links
+	SignLinks    bool // emit signed expiring links in m3u, unsigned stream links need auth
+	SignLinksTTL int  // in hours, def 24, sign key rotates with same period
+}
+
+// TorznabConfig is torznab endpoint, Host is api url with or without /api
+type TorznabConfig struct {
+	Name string
+	Host string
+	Key  string
 }
 
 func (v *BTSets) String() string {
+	if len(v.TorznabUrls) > 0 {
+		// keep api keys out of logs
+		c := *v
+		c.TorznabUrls = make([]*TorznabConfig, len(v.TorznabUrls))
+		for i, t := range v.TorznabUrls {
+			if t != nil {
+				c.TorznabUrls[i] = &TorznabConfig{Name: t.Name, Host: t.Host, Key: "***"}
+			}
+		}
+		v = &c
+	}
 	buf, _ := json.Marshal(v)
 	return string(buf)
 }
@@ -64,6 +105,14 @@ func SetBTSets(sets *BTSets) {
 		sets.ReaderReadAHead = 100
 	}
 
+	if sets.DiskCacheSize <= 0 {
+		sets.DiskCacheSize = 1024 * 1024 * 1024
+	}
+
+	if sets.SignLinksTTL <= 0 {
+		sets.SignLinksTTL = 24
+	}
+
 	if sets.PreloadCache < 0 {
 		sets.PreloadCache = 0
 	}
@@ -74,7 +123,7 @@ func SetBTSets(sets *BTSets) {
 	BTsets = sets
 	buf, err := json.Marshal(BTsets)
 	if err != nil {
-		log.Println("Error marshal btsets", err)
+		logger.Error("marshal btsets", "err", err)
 		return
 	}
 	tdb.Set("Settings", "BitTorr", buf)
@@ -82,17 +131,19 @@ func SetBTSets(sets *BTSets) {
 
 func SetDefaultConfig() {
 	sets := new(BTSets)
-	sets.CacheSize = 64 * 1024 * 1024 // 64 MB
+	sets.CacheSize = 64 * 1024 * 1024       // 64 MB
+	sets.DiskCacheSize = 1024 * 1024 * 1024 // 1 GB
 	sets.PreloadCache = 50
 	sets.ConnectionsLimit = 25
 	sets.RetrackersMode = 1
 	sets.TorrentDisconnectTimeout = 30
 	sets.ReaderReadAHead = 95 // 95%
+	sets.SignLinksTTL = 24
 	BTsets = sets
 	if !ReadOnly {
 		buf, err := json.Marshal(BTsets)
 		if err != nil {
-			log.Println("Error marshal btsets", err)
+			logger.Error("marshal btsets", "err", err)
 			return
 		}
 		tdb.Set("Settings", "BitTorr", buf)
@@ -107,10 +158,24 @@ func loadBTSets() {
 			if BTsets.ReaderReadAHead < 5 {
 				BTsets.ReaderReadAHead = 5
 			}
+			if BTsets.DiskCacheSize <= 0 {
+				BTsets.DiskCacheSize = 1024 * 1024 * 1024
+			}
+			if BTsets.SignLinksTTL <= 0 {
+				BTsets.SignLinksTTL = 24
+			}
 			return
 		}
-		log.Println("Error unmarshal btsets", err)
+		logger.Error("unmarshal btsets", "err", err)
 	}
 	// initialize defaults on error
 	SetDefaultConfig()
 }
+
+// ReloadBTSets rereads settings from DB, used after settings file is changed outside
+func ReloadBTSets() {
+	if cdb, ok := tdb.(*DBReadCache); ok {
+		cdb.Invalidate("Settings")
+	}
+	loadBTSets()
+}
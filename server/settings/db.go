@@ -5,8 +5,6 @@ import (
 	"strings"
 	"time"
 
-	"log"
-
 	bolt "go.etcd.io/bbolt"
 )
 
@@ -18,7 +16,7 @@ type TDB struct {
 func NewTDB() TorrServerDB {
 	db, err := bolt.Open(filepath.Join(Path, "config.db"), 0o666, &bolt.Options{Timeout: 5 * time.Second})
 	if err != nil {
-		log.Println(err)
+		logger.Error("open bboltDB", "err", err)
 		return nil
 	}
 
@@ -61,7 +59,7 @@ func (v *TDB) Get(xpath, name string) []byte {
 		return nil
 	})
 	if err != nil {
-		log.Println("Error get sets", xpath+"/"+name, ", error:", err)
+		logger.Error("get sets", "path", xpath+"/"+name, "err", err)
 	}
 
 	return ret
@@ -91,8 +89,7 @@ func (v *TDB) Set(xpath, name string, value []byte) {
 		return buckt.Put([]byte(name), value)
 	})
 	if err != nil {
-		log.Println("Error put sets", xpath+"/"+name, ", error:", err)
-		log.Println("value:", value)
+		logger.Error("put sets", "path", xpath+"/"+name, "err", err, "value", value)
 	}
 }
 
@@ -128,7 +125,7 @@ func (v *TDB) List(xpath string) []string {
 		return nil
 	})
 	if err != nil {
-		log.Println("Error list sets", xpath, ", error:", err)
+		logger.Error("list sets", "path", xpath, "err", err)
 	}
 
 	return ret
@@ -158,6 +155,6 @@ func (v *TDB) Rem(xpath, name string) {
 		return buckt.Delete([]byte(name))
 	})
 	if err != nil {
-		log.Println("Error rem sets", xpath+"/"+name, ", error:", err)
+		logger.Error("rem sets", "path", xpath+"/"+name, "err", err)
 	}
 }
@@ -2,8 +2,6 @@ package settings
 
 import (
 	"sync"
-
-	"log"
 )
 
 type DBReadCache struct {
@@ -47,14 +45,16 @@ func (v *DBReadCache) Get(xPath, name string) []byte {
 
 func (v *DBReadCache) Set(xPath, name string, value []byte) {
 	if ReadOnly {
-		log.Println("DB.Set: Read-only DB mode!", name)
+		logger.Warn("DB.Set: read-only DB mode", "path", xPath+"/"+name)
 		return
 	}
 	cacheKey := v.makeDataCacheKey(xPath, name)
 	v.dataCacheMutex.Lock()
 	v.dataCache[cacheKey] = value
 	v.dataCacheMutex.Unlock()
+	v.listCacheMutex.Lock()
 	delete(v.listCache, xPath)
+	v.listCacheMutex.Unlock()
 	v.db.Set(xPath, name, value)
 }
 
@@ -74,15 +74,33 @@ func (v *DBReadCache) List(xPath string) []string {
 
 func (v *DBReadCache) Rem(xPath, name string) {
 	if ReadOnly {
-		log.Println("DB.Rem: Read-only DB mode!", name)
+		logger.Warn("DB.Rem: read-only DB mode", "path", xPath+"/"+name)
 		return
 	}
 	cacheKey := v.makeDataCacheKey(xPath, name)
+	v.dataCacheMutex.Lock()
 	delete(v.dataCache, cacheKey)
+	v.dataCacheMutex.Unlock()
+	v.listCacheMutex.Lock()
 	delete(v.listCache, xPath)
+	v.listCacheMutex.Unlock()
 	v.db.Rem(xPath, name)
 }
 
 func (v *DBReadCache) makeDataCacheKey(xPath, name string) [2]string {
 	return [2]string{xPath, name}
 }
+
+// Invalidate drops cached entries of xPath, next reads go to DB
+func (v *DBReadCache) Invalidate(xPath string) {
+	v.dataCacheMutex.Lock()
+	for key := range v.dataCache {
+		if key[0] == xPath {
+			delete(v.dataCache, key)
+		}
+	}
+	v.dataCacheMutex.Unlock()
+	v.listCacheMutex.Lock()
+	delete(v.listCache, xPath)
+	v.listCacheMutex.Unlock()
+}
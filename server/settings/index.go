@@ -0,0 +1,311 @@
+package settings
+
+import (
+	"encoding/json"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/anacrolix/torrent/bencode"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// SearchQuery filters saved torrents, zero fields are not checked
+type SearchQuery struct {
+	Query    string // words, each one is matched as prefix of word of title, category, name or file path
+	Category string
+	MinSize  int64
+	MaxSize  int64
+	From     int64 // added at or after, unix time
+	To       int64 // added at or before, unix time
+	Limit    int
+}
+
+// SearchFile is file of found torrent matching words not found in torrent title
+type SearchFile struct {
+	Id     int    `json:"id"`
+	Path   string `json:"path"`
+	Length int64  `json:"length,omitempty"`
+}
+
+// SearchResult is saved torrent found by SearchTorrents
+type SearchResult struct {
+	Hash      string        `json:"hash"`
+	Title     string        `json:"title"`
+	Name      string        `json:"name,omitempty"`
+	Category  string        `json:"category,omitempty"`
+	Poster    string        `json:"poster,omitempty"`
+	Size      int64         `json:"size,omitempty"`
+	Timestamp int64         `json:"timestamp,omitempty"`
+	Files     []*SearchFile `json:"files,omitempty"`
+}
+
+type indexFile struct {
+	file  *SearchFile
+	words []string
+}
+
+type indexDoc struct {
+	res   *SearchResult
+	words []string // title, category and name
+	files []*indexFile
+}
+
+// torrentIndex is in memory full text index of torrents in DB, built on first search
+type torrentIndex struct {
+	mu    sync.Mutex
+	built bool
+	docs  map[string]*indexDoc
+	terms map[string]map[string]struct{} // word: hashes
+	words []string                       // sorted terms for prefix lookup, nil after change
+}
+
+var index = &torrentIndex{}
+
+// SearchTorrents returns saved torrents matching query, newest first, torrents matched by title go before ones matched by files
+func SearchTorrents(q *SearchQuery) []*SearchResult {
+	// lock order is mu then index.mu, as DB changes update index under mu
+	mu.Lock()
+	index.mu.Lock()
+	if !index.built {
+		index.build(listTorrent())
+	}
+	mu.Unlock()
+	defer index.mu.Unlock()
+
+	words := tokenize(q.Query)
+	var hashes map[string]struct{}
+	if len(words) > 0 {
+		hashes = index.lookup(words)
+	} else {
+		hashes = make(map[string]struct{}, len(index.docs))
+		for hash := range index.docs {
+			hashes[hash] = struct{}{}
+		}
+	}
+
+	var byTitle, byFiles []*SearchResult
+	for hash := range hashes {
+		doc := index.docs[hash]
+		if !doc.filter(q) {
+			continue
+		}
+		rest := unmatched(words, doc.words)
+		if len(rest) == 0 {
+			byTitle = append(byTitle, doc.res)
+			continue
+		}
+		var files []*SearchFile
+		for _, f := range doc.files {
+			if len(unmatched(rest, f.words)) == 0 {
+				files = append(files, f.file)
+			}
+		}
+		if len(files) > 0 {
+			res := *doc.res
+			res.Files = files
+			byFiles = append(byFiles, &res)
+		}
+	}
+	sortResults(byTitle)
+	sortResults(byFiles)
+	ret := append(byTitle, byFiles...)
+	if q.Limit > 0 && len(ret) > q.Limit {
+		ret = ret[:q.Limit]
+	}
+	return ret
+}
+
+func sortResults(list []*SearchResult) {
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Timestamp != list[j].Timestamp {
+			return list[i].Timestamp > list[j].Timestamp
+		}
+		return list[i].Hash < list[j].Hash
+	})
+}
+
+func (d *indexDoc) filter(q *SearchQuery) bool {
+	r := d.res
+	if q.Category != "" && !strings.EqualFold(r.Category, q.Category) {
+		return false
+	}
+	if q.MinSize > 0 && r.Size < q.MinSize {
+		return false
+	}
+	if q.MaxSize > 0 && r.Size > q.MaxSize {
+		return false
+	}
+	if q.From > 0 && r.Timestamp < q.From {
+		return false
+	}
+	if q.To > 0 && r.Timestamp > q.To {
+		return false
+	}
+	return true
+}
+
+func (idx *torrentIndex) build(list []*TorrentDB) {
+	idx.docs = make(map[string]*indexDoc, len(list))
+	idx.terms = make(map[string]map[string]struct{})
+	idx.words = nil
+	for _, t := range list {
+		idx.add(t)
+	}
+	idx.built = true
+}
+
+func (idx *torrentIndex) add(t *TorrentDB) {
+	if t.TorrentSpec == nil {
+		return
+	}
+	hash := t.InfoHash.HexString()
+	idx.rem(hash)
+
+	doc := &indexDoc{res: &SearchResult{
+		Hash:      hash,
+		Title:     t.Title,
+		Name:      torrentName(t),
+		Category:  t.Category,
+		Poster:    t.Poster,
+		Size:      t.Size,
+		Timestamp: t.Timestamp,
+	}}
+	all := map[string]struct{}{}
+	doc.words = tokenize(doc.res.Title + " " + doc.res.Category + " " + doc.res.Name)
+	for _, w := range doc.words {
+		all[w] = struct{}{}
+	}
+	for _, f := range dataFiles(t.Data) {
+		file := &indexFile{file: f, words: tokenize(f.Path)}
+		for _, w := range file.words {
+			all[w] = struct{}{}
+		}
+		doc.files = append(doc.files, file)
+	}
+
+	idx.docs[hash] = doc
+	for w := range all {
+		if idx.terms[w] == nil {
+			idx.terms[w] = make(map[string]struct{})
+			idx.words = nil
+		}
+		idx.terms[w][hash] = struct{}{}
+	}
+}
+
+func (idx *torrentIndex) rem(hash string) {
+	doc := idx.docs[hash]
+	if doc == nil {
+		return
+	}
+	delete(idx.docs, hash)
+	words := slices.Clone(doc.words)
+	for _, f := range doc.files {
+		words = append(words, f.words...)
+	}
+	for _, w := range words {
+		if hashes := idx.terms[w]; hashes != nil {
+			delete(hashes, hash)
+			if len(hashes) == 0 {
+				delete(idx.terms, w)
+				idx.words = nil
+			}
+		}
+	}
+}
+
+// lookup returns hashes of torrents having all words as prefixes of own words
+func (idx *torrentIndex) lookup(words []string) map[string]struct{} {
+	if idx.words == nil {
+		idx.words = make([]string, 0, len(idx.terms))
+		for w := range idx.terms {
+			idx.words = append(idx.words, w)
+		}
+		sort.Strings(idx.words)
+	}
+	var ret map[string]struct{}
+	for _, w := range words {
+		found := map[string]struct{}{}
+		for i := sort.SearchStrings(idx.words, w); i < len(idx.words) && strings.HasPrefix(idx.words[i], w); i++ {
+			for hash := range idx.terms[idx.words[i]] {
+				if ret == nil {
+					found[hash] = struct{}{}
+				} else if _, ok := ret[hash]; ok {
+					found[hash] = struct{}{}
+				}
+			}
+		}
+		ret = found
+		if len(ret) == 0 {
+			break
+		}
+	}
+	return ret
+}
+
+// indexTorrent updates torrent in index if it is built, mu must be held
+func indexTorrent(t *TorrentDB) {
+	index.mu.Lock()
+	if index.built {
+		index.add(t)
+	}
+	index.mu.Unlock()
+}
+
+// unindexTorrent removes torrent from index if it is built, mu must be held
+func unindexTorrent(hash metainfo.Hash) {
+	index.mu.Lock()
+	if index.built {
+		index.rem(hash.HexString())
+	}
+	index.mu.Unlock()
+}
+
+// unmatched returns query words which are not prefix of any of words
+func unmatched(query, words []string) []string {
+	var ret []string
+	for _, q := range query {
+		if !slices.ContainsFunc(words, func(w string) bool { return strings.HasPrefix(w, q) }) {
+			ret = append(ret, q)
+		}
+	}
+	return ret
+}
+
+// tokenize splits s to unique lower case words of letters and digits
+func tokenize(s string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	slices.Sort(fields)
+	return slices.Compact(fields)
+}
+
+func torrentName(t *TorrentDB) string {
+	if len(t.InfoBytes) > 0 {
+		var info metainfo.Info
+		if err := bencode.Unmarshal(t.InfoBytes, &info); err == nil && info.Name != "" {
+			return info.Name
+		}
+	}
+	return t.DisplayName
+}
+
+// dataFiles returns files saved by server in torrent data
+func dataFiles(data string) []*SearchFile {
+	if data == "" {
+		return nil
+	}
+	var files struct {
+		TorrServer struct {
+			Files []*SearchFile `json:"Files"`
+		} `json:"TorrServer"`
+	}
+	if err := json.Unmarshal([]byte(data), &files); err != nil {
+		return nil
+	}
+	return files.TorrServer.Files
+}
@@ -0,0 +1,177 @@
+package settings
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// memDB is in memory TorrServerDB
+type memDB struct {
+	mu   sync.Mutex
+	data map[string]map[string][]byte
+}
+
+func (m *memDB) CloseDB() {}
+
+func (m *memDB) Get(xPath, name string) []byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.data[xPath][name]
+}
+
+func (m *memDB) Set(xPath, name string, value []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.data[xPath] == nil {
+		m.data[xPath] = map[string][]byte{}
+	}
+	m.data[xPath][name] = value
+}
+
+func (m *memDB) List(xPath string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var ret []string
+	for name := range m.data[xPath] {
+		ret = append(ret, name)
+	}
+	return ret
+}
+
+func (m *memDB) Rem(xPath, name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.data[xPath], name)
+}
+
+func setTestDB(t *testing.T) {
+	oldDB, oldIndex := tdb, index
+	t.Cleanup(func() { tdb, index = oldDB, oldIndex })
+	tdb = &memDB{data: map[string]map[string][]byte{}}
+	index = &torrentIndex{}
+}
+
+func testTorrent(n int, title, category string, size, ts int64, files ...string) *TorrentDB {
+	data := `{"TorrServer":{"Files":[`
+	for i, f := range files {
+		if i > 0 {
+			data += ","
+		}
+		data += fmt.Sprintf(`{"id":%d,"path":%q,"length":%d}`, i+1, f, size)
+	}
+	data += `]}}`
+	return &TorrentDB{
+		TorrentSpec: &torrent.TorrentSpec{InfoHash: metainfo.Hash{byte(n)}, DisplayName: fmt.Sprintf("name%d", n)},
+		Title:       title,
+		Category:    category,
+		Data:        data,
+		Size:        size,
+		Timestamp:   ts,
+	}
+}
+
+func hashes(list []*SearchResult) []string {
+	var ret []string
+	for _, r := range list {
+		ret = append(ret, r.Hash)
+	}
+	return ret
+}
+
+func TestSearchTorrents(t *testing.T) {
+	setTestDB(t)
+	t1 := testTorrent(1, "Matrix 1999 1080p", "movie", 10<<30, 100, "Matrix.mkv", "Extras/Making of.mkv")
+	t2 := testTorrent(2, "Matrix Reloaded", "movie", 12<<30, 200, "Reloaded.mkv")
+	t3 := testTorrent(3, "Friends S01", "tv", 5<<30, 300, "Friends.S01E01.mkv", "Friends.S01E02.mkv")
+	AddTorrent(t1)
+	AddTorrent(t2)
+	AddTorrent(t3)
+	h1, h2, h3 := t1.InfoHash.HexString(), t2.InfoHash.HexString(), t3.InfoHash.HexString()
+
+	for _, tc := range []struct {
+		name string
+		q    SearchQuery
+		want []string
+	}{
+		{"all newest first", SearchQuery{}, []string{h3, h2, h1}},
+		{"prefix", SearchQuery{Query: "matr"}, []string{h2, h1}},
+		{"all words", SearchQuery{Query: "MATRIX reload"}, []string{h2}},
+		{"name", SearchQuery{Query: "name3"}, []string{h3}},
+		{"title before files", SearchQuery{Query: "reloaded"}, []string{h2}},
+		{"category", SearchQuery{Category: "TV"}, []string{h3}},
+		{"size", SearchQuery{MinSize: 6 << 30, MaxSize: 11 << 30}, []string{h1}},
+		{"time", SearchQuery{From: 150, To: 250}, []string{h2}},
+		{"limit", SearchQuery{Limit: 1}, []string{h3}},
+		{"no match", SearchQuery{Query: "matrix friends"}, nil},
+	} {
+		if got := hashes(SearchTorrents(&tc.q)); !slices.Equal(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	res := SearchTorrents(&SearchQuery{Query: "matrix making"})
+	if len(res) != 1 || res[0].Hash != h1 || len(res[0].Files) != 1 || res[0].Files[0].Path != "Extras/Making of.mkv" {
+		t.Errorf("file match %+v", res)
+	}
+	res = SearchTorrents(&SearchQuery{Query: "s01e02"})
+	if len(res) != 1 || len(res[0].Files) != 1 || res[0].Files[0].Id != 2 {
+		t.Errorf("file match %+v", res)
+	}
+
+	// built index follows DB changes
+	RemTorrent(t2.InfoHash)
+	t1.Title = "Neo"
+	AddTorrent(t1)
+	res = SearchTorrents(&SearchQuery{Query: "matrix"})
+	if len(res) != 1 || res[0].Hash != h1 || len(res[0].Files) != 1 {
+		t.Errorf("removed or renamed torrents found by title %+v", res)
+	}
+	if got := hashes(SearchTorrents(&SearchQuery{Query: "neo"})); !slices.Equal(got, []string{h1}) {
+		t.Errorf("renamed torrent: got %v", got)
+	}
+}
+
+func TestSearchTorrentsConcurrent(t *testing.T) {
+	setTestDB(t)
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			tor := testTorrent(i, "Movie", "movie", 1, int64(i))
+			AddTorrent(tor)
+			if i%2 == 0 {
+				RemTorrent(tor.InfoHash)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			SearchTorrents(&SearchQuery{Query: "movie"})
+		}()
+	}
+	wg.Wait()
+
+	var want []string
+	for _, tor := range ListTorrent() {
+		want = append(want, tor.InfoHash.HexString())
+	}
+	got := hashes(SearchTorrents(&SearchQuery{Query: "movie"}))
+	slices.Sort(want)
+	slices.Sort(got)
+	if len(got) != 10 || !slices.Equal(got, want) {
+		t.Errorf("index %v, DB %v", got, want)
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	got := tokenize("The.Matrix (1999) [1080p] matrix Матрица")
+	want := []string{"1080p", "1999", "matrix", "the", "матрица"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -9,8 +9,6 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
-
-	"log"
 )
 
 type JsonDB struct {
@@ -155,8 +153,8 @@ func (v *JsonDB) writeMapAsJsonFile(filename string, o map[string]any) error {
 
 func (v *JsonDB) log(s string, params ...any) {
 	if len(params) > 0 {
-		log.Printf("JsonDB: %s: %s\n", s, fmt.Sprint(params...))
+		logger.Error("JsonDB: "+s, "err", fmt.Sprint(params...))
 	} else {
-		log.Printf("JsonDB: %s\n", s)
+		logger.Info("JsonDB: " + s)
 	}
 }
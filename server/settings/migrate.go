@@ -10,7 +10,6 @@ import (
 	"reflect"
 	"time"
 
-	"log"
 	"server/web/api/utils"
 
 	bolt "go.etcd.io/bbolt"
@@ -35,7 +34,7 @@ func MigrateTorrents() {
 
 	db, err := bolt.Open(filepath.Join(Path, "torrserver.db"), 0o666, &bolt.Options{Timeout: 5 * time.Second})
 	if err != nil {
-		log.Println("MigrateTorrents", err)
+		logger.Error("migrate torrents", "err", err)
 		return
 	}
 
@@ -92,7 +91,7 @@ func MigrateTorrents() {
 			if len(spec.DisplayName) > len(title) {
 				title = spec.DisplayName
 			}
-			log.Println("Migrate torrent", torr.Name, torr.Hash, torr.Size)
+			logger.Info("migrate torrent", "name", torr.Name, "hash", torr.Hash, "size", torr.Size)
 			AddTorrent(&TorrentDB{
 				TorrentSpec: spec,
 				Title:       title,
@@ -128,7 +127,7 @@ func MigrateToJson(bboltDB, jsonDB TorrServerDB) error {
 
 	if BTsets != nil {
 		msg := "Migrate0002 MUST be called before initializing BTSets"
-		log.Println(msg)
+		logger.Error(msg)
 		os.Exit(1)
 	}
 
@@ -152,20 +151,20 @@ func MigrateToJson(bboltDB, jsonDB TorrServerDB) error {
 		if jsonDB.Get(xPath, name) == nil {
 			bboltDBBlob := bboltDB.Get(xPath, name)
 			if bboltDBBlob != nil {
-				log.Printf("Attempting to migrate %s->%s from TDB to JsonDB\n", xPath, name)
+				logger.Info("attempting to migrate from TDB to JsonDB", "path", xPath+"/"+name)
 				jsonDB.Set(xPath, name, bboltDBBlob)
 				jsonDBBlob := jsonDB.Get(xPath, name)
 				if isEqual, err := isByteArraysEqualJson(bboltDBBlob, jsonDBBlob); err == nil {
 					if isEqual {
-						log.Printf("Migrated %s->%s successful\n", xPath, name)
+						logger.Info("migrated successful", "path", xPath+"/"+name)
 					} else {
 						msg := fmt.Sprintf("Failed to migrate %s->%s TDB to JsonDB: equality check failed", xPath, name)
-						log.Println(msg)
+						logger.Error(msg)
 						return errors.New(msg)
 					}
 				} else {
 					msg := fmt.Sprintf("Failed to migrate %s->%s TDB to JsonDB: %s", xPath, name, err)
-					log.Println(msg)
+					logger.Error(msg)
 					return errors.New(msg)
 				}
 			}
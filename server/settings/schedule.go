@@ -0,0 +1,67 @@
+package settings
+
+import (
+	"slices"
+	"time"
+)
+
+type BandwidthProfile struct {
+	Name              string
+	DownloadRateLimit int // in kb, 0 - inf
+	UploadRateLimit   int // in kb, 0 - inf
+	ConnectionsLimit  int // 0 - settings limit
+}
+
+// BandwidthRule activates profile by weekday and time of day
+type BandwidthRule struct {
+	Profile  string
+	Weekdays []int  // 0 - Sunday ... 6 - Saturday, empty - every day
+	Start    string // 15:04, local time
+	End      string // 15:04, end before start spans midnight
+}
+
+func parseDayTime(s string) (time.Duration, bool) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, true
+}
+
+// match reports rule is active at t, rules spanning midnight belong to weekday of start
+func (r *BandwidthRule) match(t time.Time) bool {
+	start, ok1 := parseDayTime(r.Start)
+	end, ok2 := parseDayTime(r.End)
+	if !ok1 || !ok2 || start == end {
+		return false
+	}
+	now := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	day := int(t.Weekday())
+	if start > end && now < end {
+		// after midnight of rule started yesterday
+		day = (day + 6) % 7
+		now += 24 * time.Hour
+		end += 24 * time.Hour
+	} else if start > end {
+		end += 24 * time.Hour
+	}
+	if len(r.Weekdays) > 0 && !slices.Contains(r.Weekdays, day) {
+		return false
+	}
+	return now >= start && now < end
+}
+
+// BandwidthProfileAt returns profile of first schedule rule matching t, nil if none
+func (v *BTSets) BandwidthProfileAt(t time.Time) *BandwidthProfile {
+	for _, r := range v.BandwidthSchedule {
+		if r == nil || !r.match(t) {
+			continue
+		}
+		for _, p := range v.BandwidthProfiles {
+			if p != nil && p.Name == r.Profile {
+				return p
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package settings
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// max stream sessions kept in history, oldest are removed
+const maxStreamSessions = 1000
+
+// StreamSession is record of one http stream of torrent file
+type StreamSession struct {
+	Hash      string  `json:"hash"`
+	FileIndex int     `json:"file_index"`
+	Remote    string  `json:"remote"`
+	UserAgent string  `json:"user_agent,omitempty"`
+	Range     string  `json:"range,omitempty"` // requested byte ranges, empty for whole file
+	From      int64   `json:"from"`            // served bytes of file are [from, to), -1 for multipart ranges
+	To        int64   `json:"to"`
+	Status    int     `json:"status"`
+	BytesSent int64   `json:"bytes_sent"`
+	Start     int64   `json:"start"`    // unix time in ms
+	Duration  float64 `json:"duration"` // in seconds
+	Reason    string  `json:"reason"`   // why stream stopped: completed, client closed, write error, torrent closed, shutdown
+}
+
+var muSessions sync.Mutex
+
+// AddStreamSession saves session to history, history is trimmed to maxStreamSessions
+func AddStreamSession(s *StreamSession) {
+	if ReadOnly {
+		return
+	}
+	buf, err := json.Marshal(s)
+	if err != nil {
+		logger.Error("marshal stream session", "err", err)
+		return
+	}
+	muSessions.Lock()
+	defer muSessions.Unlock()
+	// start time key keeps history sorted, same ms sessions get distinct keys by remote
+	tdb.Set("Sessions", fmt.Sprintf("%016d-%s", s.Start, s.Remote), buf)
+
+	keys := tdb.List("Sessions")
+	if len(keys) > maxStreamSessions {
+		sort.Strings(keys)
+		for _, key := range keys[:len(keys)-maxStreamSessions] {
+			tdb.Rem("Sessions", key)
+		}
+	}
+}
+
+// ListStreamSessions returns sessions newest first, filtered by hash if not empty, limit <= 0 - all
+func ListStreamSessions(hash string, limit int) []*StreamSession {
+	muSessions.Lock()
+	keys := append([]string(nil), tdb.List("Sessions")...)
+	muSessions.Unlock()
+	sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+
+	ret := []*StreamSession{}
+	for _, key := range keys {
+		buf := tdb.Get("Sessions", key)
+		if len(buf) == 0 {
+			continue
+		}
+		var s *StreamSession
+		if err := json.Unmarshal(buf, &s); err != nil {
+			logger.Error("unmarshal stream session", "key", key, "err", err)
+			continue
+		}
+		if hash != "" && s.Hash != hash {
+			continue
+		}
+		ret = append(ret, s)
+		if limit > 0 && len(ret) >= limit {
+			break
+		}
+	}
+	return ret
+}
+
+// ClearStreamSessions removes all sessions from history
+func ClearStreamSessions() {
+	muSessions.Lock()
+	defer muSessions.Unlock()
+	for _, key := range tdb.List("Sessions") {
+		tdb.Rem("Sessions", key)
+	}
+}
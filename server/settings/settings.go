@@ -4,9 +4,11 @@ import (
 	"os"
 	"path/filepath"
 
-	"log"
+	"server/logs"
 )
 
+var logger = logs.For("settings")
+
 var (
 	tdb      TorrServerDB
 	Path     string
@@ -16,6 +18,13 @@ var (
 	PubIPv6  string
 	TorAddr  string
 	MaxSize  int64
+	HttpAuth bool
+	Ssl      bool
+	SslAddr  string
+	SslCert  string
+	SslKey   string
+	Debug    bool
+	DlnaAddr string
 )
 
 func InitSets(readOnly bool) {
@@ -23,13 +32,13 @@ func InitSets(readOnly bool) {
 
 	bboltDB := NewTDB()
 	if bboltDB == nil {
-		log.Println("Error open bboltDB:", filepath.Join(Path, "config.db"))
+		logger.Error("open bboltDB", "path", filepath.Join(Path, "config.db"))
 		os.Exit(1)
 	}
 
 	jsonDB := NewJsonDB()
 	if jsonDB == nil {
-		log.Println("Error open jsonDB")
+		logger.Error("open jsonDB", "path", Path)
 		os.Exit(1)
 	}
 
@@ -38,12 +47,13 @@ func InitSets(readOnly bool) {
 	dbRouter.RegisterRoute(jsonDB, "Settings")
 	dbRouter.RegisterRoute(jsonDB, "Viewed")
 	dbRouter.RegisterRoute(bboltDB, "Torrents")
+	dbRouter.RegisterRoute(bboltDB, "Sessions")
 
 	tdb = NewDBReadCache(dbRouter)
 
 	// We migrate settings here, it must be done before loadBTSets()
 	if err := MigrateToJson(bboltDB, jsonDB); err != nil {
-		log.Println("MigrateToJson failed")
+		logger.Error("migrate to json failed", "err", err)
 		os.Exit(1)
 	}
 	loadBTSets()
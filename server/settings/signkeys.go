@@ -0,0 +1,34 @@
+package settings
+
+import (
+	"encoding/json"
+)
+
+// SignKeys are hmac keys of signed stream links, previous key is kept to check links signed before rotation
+type SignKeys struct {
+	Current  []byte `json:"current"`
+	Previous []byte `json:"previous,omitempty"`
+	Rotated  int64  `json:"rotated"` // unix time
+}
+
+func GetSignKeys() *SignKeys {
+	buf := tdb.Get("Settings", "SignKeys")
+	if len(buf) == 0 {
+		return nil
+	}
+	keys := new(SignKeys)
+	if err := json.Unmarshal(buf, keys); err != nil {
+		logger.Error("unmarshal sign keys", "err", err)
+		return nil
+	}
+	return keys
+}
+
+func SetSignKeys(keys *SignKeys) {
+	buf, err := json.Marshal(keys)
+	if err != nil {
+		logger.Error("marshal sign keys", "err", err)
+		return
+	}
+	tdb.Set("Settings", "SignKeys", buf)
+}
@@ -19,6 +19,9 @@ type TorrentDB struct {
 
 	Timestamp int64 `json:"timestamp,omitempty"`
 	Size      int64 `json:"size,omitempty"`
+
+	DownloadRateLimit int `json:"download_rate_limit,omitempty"` // in kb, 0 - no cap
+	UploadRateLimit   int `json:"upload_rate_limit,omitempty"`   // in kb, 0 - no cap
 }
 
 type File struct {
@@ -30,8 +33,8 @@ type File struct {
 var mu sync.Mutex
 
 func AddTorrent(torr *TorrentDB) {
-	list := ListTorrent()
 	mu.Lock()
+	list := listTorrent()
 	find := -1
 	for i, db := range list {
 		if db.InfoHash.HexString() == torr.InfoHash.HexString() {
@@ -50,13 +53,18 @@ func AddTorrent(torr *TorrentDB) {
 			tdb.Set("Torrents", db.InfoHash.HexString(), buf)
 		}
 	}
+	// index is changed under same lock, so it follows DB
+	indexTorrent(torr)
 	mu.Unlock()
 }
 
 func ListTorrent() []*TorrentDB {
 	mu.Lock()
 	defer mu.Unlock()
+	return listTorrent()
+}
 
+func listTorrent() []*TorrentDB {
 	var list []*TorrentDB
 	keys := tdb.List("Torrents")
 	for _, key := range keys {
@@ -78,5 +86,6 @@ func ListTorrent() []*TorrentDB {
 func RemTorrent(hash metainfo.Hash) {
 	mu.Lock()
 	tdb.Rem("Torrents", hash.HexString())
+	unindexTorrent(hash)
 	mu.Unlock()
 }
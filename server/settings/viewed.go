@@ -2,23 +2,28 @@ package settings
 
 import (
 	"encoding/json"
-
-	"log"
+	"time"
 )
 
 type Viewed struct {
 	Hash      string `json:"hash"`
 	FileIndex int    `json:"file_index"`
+	Time      int64  `json:"time,omitempty"` // unix time of last view, empty for old views
+}
+
+// viewedFile is stored by file index, empty object for files viewed before time was saved
+type viewedFile struct {
+	Time int64 `json:"time,omitempty"`
 }
 
 func SetViewed(vv *Viewed) {
-	var indexes map[int]struct{}
+	var indexes map[int]viewedFile
 	var err error
 
 	buf := tdb.Get("Viewed", vv.Hash)
 	if len(buf) == 0 {
-		indexes = make(map[int]struct{})
-		indexes[vv.FileIndex] = struct{}{}
+		indexes = make(map[int]viewedFile)
+		indexes[vv.FileIndex] = viewedFile{time.Now().Unix()}
 		buf, err = json.Marshal(indexes)
 		if err == nil {
 			tdb.Set("Viewed", vv.Hash, buf)
@@ -26,7 +31,7 @@ func SetViewed(vv *Viewed) {
 	} else {
 		err = json.Unmarshal(buf, &indexes)
 		if err == nil {
-			indexes[vv.FileIndex] = struct{}{}
+			indexes[vv.FileIndex] = viewedFile{time.Now().Unix()}
 			buf, err = json.Marshal(indexes)
 			if err == nil {
 				tdb.Set("Viewed", vv.Hash, buf)
@@ -34,13 +39,13 @@ func SetViewed(vv *Viewed) {
 		}
 	}
 	if err != nil {
-		log.Println("Error set viewed:", err)
+		logger.Error("set viewed", "hash", vv.Hash, "file", vv.FileIndex, "err", err)
 	}
 }
 
 func RemViewed(vv *Viewed) {
 	buf := tdb.Get("Viewed", vv.Hash)
-	var indeces map[int]struct{}
+	var indeces map[int]viewedFile
 	err := json.Unmarshal(buf, &indeces)
 	if err == nil {
 		if vv.FileIndex != -1 {
@@ -54,7 +59,7 @@ func RemViewed(vv *Viewed) {
 		}
 	}
 	if err != nil {
-		log.Println("Error rem viewed:", err)
+		logger.Error("rem viewed", "hash", vv.Hash, "file", vv.FileIndex, "err", err)
 	}
 }
 
@@ -65,12 +70,12 @@ func ListViewed(hash string) []*Viewed {
 		if len(buf) == 0 {
 			return []*Viewed{}
 		}
-		var indeces map[int]struct{}
+		var indeces map[int]viewedFile
 		err = json.Unmarshal(buf, &indeces)
 		if err == nil {
 			var ret []*Viewed
-			for i := range indeces {
-				ret = append(ret, &Viewed{hash, i})
+			for i, v := range indeces {
+				ret = append(ret, &Viewed{hash, i, v.Time})
 			}
 			return ret
 		}
@@ -82,17 +87,26 @@ func ListViewed(hash string) []*Viewed {
 			if len(buf) == 0 {
 				return []*Viewed{}
 			}
-			var indeces map[int]struct{}
+			var indeces map[int]viewedFile
 			err = json.Unmarshal(buf, &indeces)
 			if err == nil {
-				for i := range indeces {
-					ret = append(ret, &Viewed{key, i})
+				for i, v := range indeces {
+					ret = append(ret, &Viewed{key, i, v.Time})
 				}
 			}
 		}
 		return ret
 	}
 
-	log.Println("Error list viewed:", err)
+	logger.Error("list viewed", "hash", hash, "err", err)
 	return []*Viewed{}
 }
+
+// LastViewed returns unix time of last viewed file by torrent hash
+func LastViewed() map[string]int64 {
+	ret := make(map[string]int64)
+	for _, v := range ListViewed("") {
+		ret[v.Hash] = max(ret[v.Hash], v.Time)
+	}
+	return ret
+}
@@ -6,8 +6,6 @@ import (
 	"sort"
 	"strings"
 
-	"log"
-
 	"golang.org/x/exp/slices"
 )
 
@@ -111,8 +109,8 @@ func (v *XPathDBRouter) getDBName(db TorrServerDB) string {
 
 func (v *XPathDBRouter) log(s string, params ...any) {
 	if len(params) > 0 {
-		log.Printf("XPathDBRouter: %s: %s", s, fmt.Sprint(params...))
+		logger.Debug("XPathDBRouter: "+s, "params", fmt.Sprint(params...))
 	} else {
-		log.Printf("XPathDBRouter: %s", s)
+		logger.Debug("XPathDBRouter: " + s)
 	}
 }
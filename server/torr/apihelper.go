@@ -2,14 +2,13 @@ package torr
 
 import (
 	"io"
-	"os"
 	"sort"
+	"sync"
 	"time"
 
 	"github.com/anacrolix/torrent"
 	"github.com/anacrolix/torrent/metainfo"
 
-	"log"
 	sets "server/settings"
 )
 
@@ -39,7 +38,7 @@ func LoadTorrent(tor *Torrent) *Torrent {
 func AddTorrent(spec *torrent.TorrentSpec, title, poster string, data string, category string) (*Torrent, error) {
 	torr, err := NewTorrent(spec, bts)
 	if err != nil {
-		log.Println("error add torrent:", err)
+		logger.Error("add torrent", "hash", spec.InfoHash.HexString(), "err", err)
 		return nil, err
 	}
 
@@ -76,11 +75,16 @@ func AddTorrent(spec *torrent.TorrentSpec, title, poster string, data string, ca
 		}
 	}
 
+	if torDB != nil && torr.DownloadRateLimit == 0 && torr.UploadRateLimit == 0 {
+		torr.DownloadRateLimit = torDB.DownloadRateLimit
+		torr.UploadRateLimit = torDB.UploadRateLimit
+	}
+
 	return torr, nil
 }
 
 func SaveTorrentToDB(torr *Torrent) {
-	log.Println("save to db:", torr.Hash())
+	logger.Info("save to db", "hash", torr.Hash().HexString())
 	AddTorrentDB(torr)
 }
 
@@ -97,7 +101,7 @@ func GetTorrent(hashHex string) *Torrent {
 	if tr != nil {
 		tor = tr
 		go func() {
-			log.Println("New torrent", tor.Hash())
+			logger.Info("new torrent", "hash", tor.Hash().HexString())
 			tr, _ := NewTorrent(tor.TorrentSpec, bts)
 			if tr != nil {
 				tr.Title = tor.Title
@@ -106,6 +110,8 @@ func GetTorrent(hashHex string) *Torrent {
 				tr.Size = tor.Size
 				tr.Timestamp = tor.Timestamp
 				tr.Category = tor.Category
+				tr.DownloadRateLimit = tor.DownloadRateLimit
+				tr.UploadRateLimit = tor.UploadRateLimit
 				tr.GotInfo()
 			}
 		}()
@@ -130,12 +136,14 @@ func SetTorrent(hashHex, title, poster, category string, data string) *Torrent {
 		if title == "" && torr.Torrent != nil && torr.Torrent.Info() != nil {
 			title = torr.Info().Name
 		}
+		torr.muTorrent.Lock()
 		torr.Title = title
 		torr.Poster = poster
 		torr.Category = category
 		if data != "" {
 			torr.Data = data
 		}
+		torr.muTorrent.Unlock()
 	}
 	// update torrent data in DB
 	if torrDb != nil {
@@ -154,9 +162,47 @@ func SetTorrent(hashHex, title, poster, category string, data string) *Torrent {
 	}
 }
 
+// SetRateLimits temporarily changes global rate limits of running client in kb, 0 - inf
+func SetRateLimits(download, upload int) {
+	bts.SetRateLimits(download, upload)
+}
+
+// GetRateLimits returns global rate limits of running client in kb
+func GetRateLimits() (download, upload int) {
+	return bts.RateLimits()
+}
+
+// ActiveBandwidthProfile returns name of bandwidth profile active by schedule
+func ActiveBandwidthProfile() string {
+	return bts.ActiveProfile()
+}
+
+// SetTorrentLimits sets download and upload caps of torrent in kb, 0 - no cap,
+// caps are saved with torrent and kept approximately by pausing its data transfer
+func SetTorrentLimits(hashHex string, download, upload int) *Torrent {
+	hash := metainfo.NewHashFromHex(hashHex)
+	torr := bts.GetTorrent(hash)
+	torrDb := GetTorrentDB(hash)
+	if torr != nil {
+		torr.muTorrent.Lock()
+		torr.DownloadRateLimit = download
+		torr.UploadRateLimit = upload
+		torr.muTorrent.Unlock()
+	}
+	if torrDb != nil {
+		torrDb.DownloadRateLimit = download
+		torrDb.UploadRateLimit = upload
+		AddTorrentDB(torrDb)
+	}
+	if torr != nil {
+		return torr
+	}
+	return torrDb
+}
+
 func RemTorrent(hashHex string) {
 	if sets.ReadOnly {
-		log.Println("API RemTorrent: Read-only DB mode!", hashHex)
+		logger.Warn("API RemTorrent: read-only DB mode", "hash", hashHex)
 		return
 	}
 	hash := metainfo.NewHashFromHex(hashHex)
@@ -189,6 +235,18 @@ func ListTorrent() []*Torrent {
 	return ret
 }
 
+// ListActiveTorrents returns torrents of running client sorted by hash, torrents only in DB are skipped
+func ListActiveTorrents() []*Torrent {
+	var ret []*Torrent
+	for _, t := range bts.ListTorrents() {
+		ret = append(ret, t)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Hash().HexString() < ret[j].Hash().HexString()
+	})
+	return ret
+}
+
 func DropTorrent(hashHex string) {
 	hash := metainfo.NewHashFromHex(hashHex)
 	bts.RemoveTorrent(hash)
@@ -196,36 +254,60 @@ func DropTorrent(hashHex string) {
 
 func SetSettings(set *sets.BTSets) {
 	if sets.ReadOnly {
-		log.Println("API SetSettings: Read-only DB mode!")
+		logger.Warn("API SetSettings: read-only DB mode")
 		return
 	}
+	old := *sets.BTsets
 	sets.SetBTSets(set)
-	log.Println("drop all torrents")
-	dropAllTorrent()
-	time.Sleep(time.Second * 1)
-	log.Println("disconect")
-	bts.Disconnect()
-	log.Println("connect")
-	bts.Connect()
-	time.Sleep(time.Second * 1)
-	log.Println("end set settings")
+	applySettings(&old)
+	logger.Info("end set settings")
 }
 
 func SetDefSettings() {
 	if sets.ReadOnly {
-		log.Println("API SetDefSettings: Read-only DB mode!")
+		logger.Warn("API SetDefSettings: read-only DB mode")
 		return
 	}
+	old := *sets.BTsets
 	sets.SetDefaultConfig()
-	log.Println("drop all torrents")
+	applySettings(&old)
+	logger.Info("end set default settings")
+}
+
+// needReconnect reports settings changes which can't be applied to running client
+func needReconnect(old, cur *sets.BTSets) bool {
+	// listener
+	if old.PeersListenPort != cur.PeersListenPort || old.EnableIPv6 != cur.EnableIPv6 ||
+		old.DisableTCP != cur.DisableTCP || old.DisableUTP != cur.DisableUTP || old.DisableUPNP != cur.DisableUPNP {
+		return true
+	}
+	// DHT and peer exchange
+	if old.DisableDHT != cur.DisableDHT || old.DisablePEX != cur.DisablePEX || old.DisableUpload != cur.DisableUpload {
+		return true
+	}
+	// encryption
+	if old.ForceEncrypt != cur.ForceEncrypt {
+		return true
+	}
+	// client storage is created on connect
+	return old.UseDisk != cur.UseDisk || old.TorrentsSavePath != cur.TorrentsSavePath
+}
+
+// applySettings reconnects client if needed, otherwise applies settings to running client
+func applySettings(old *sets.BTSets) {
+	if !needReconnect(old, sets.BTsets) {
+		logger.Info("apply settings to running client")
+		bts.Apply()
+		return
+	}
+	logger.Info("drop all torrents")
 	dropAllTorrent()
 	time.Sleep(time.Second * 1)
-	log.Println("disconect")
+	logger.Info("disconnect")
 	bts.Disconnect()
-	log.Println("connect")
+	logger.Info("connect")
 	bts.Connect()
 	time.Sleep(time.Second * 1)
-	log.Println("end set default settings")
 }
 
 func dropAllTorrent() {
@@ -235,14 +317,37 @@ func dropAllTorrent() {
 	}
 }
 
+// ReloadSettings rereads settings from DB and applies them
+func ReloadSettings() {
+	old := *sets.BTsets
+	sets.ReloadBTSets()
+	applySettings(&old)
+	logger.Info("end reload settings")
+}
+
+var (
+	shutdownChan = make(chan struct{})
+	shutdownOnce sync.Once
+)
+
+// Shutdown requests graceful shutdown of server
 func Shutdown() {
-	bts.Disconnect()
-	sets.CloseDB()
-	log.Println("Received shutdown. Quit")
-	os.Exit(0)
+	shutdownOnce.Do(func() {
+		logger.Info("received shutdown")
+		close(shutdownChan)
+	})
+}
+
+// ShutdownRequested returns channel closed on shutdown request
+func ShutdownRequested() <-chan struct{} {
+	return shutdownChan
 }
 
 func WriteStatus(w io.Writer) {
+	if bts.client == nil {
+		io.WriteString(w, "torrent client is not connected\n")
+		return
+	}
 	bts.client.WriteStatus(w)
 }
 
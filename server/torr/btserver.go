@@ -3,7 +3,6 @@ package torr
 import (
 	"context"
 	"fmt"
-	"log"
 	"maps"
 	"net"
 	"sync"
@@ -12,18 +11,29 @@ import (
 	"github.com/anacrolix/torrent"
 	"github.com/anacrolix/torrent/metainfo"
 
+	"server/logs"
 	"server/settings"
 	"server/torr/storage/torrstor"
 	"server/torr/utils"
 	"server/version"
 )
 
+var logger = logs.For("torr")
+
 type BTServer struct {
 	config *torrent.ClientConfig
 	client *torrent.Client
 
 	storage *torrstor.Storage
 
+	// current global limits in kb
+	downloadLimit int
+	uploadLimit   int
+
+	// active bandwidth profile, empty for settings limits
+	profile      string
+	scheduleOnce sync.Once
+
 	torrents map[metainfo.Hash]*Torrent
 
 	mu sync.Mutex
@@ -60,9 +70,14 @@ func (bt *BTServer) Connect() error {
 	bt.mu.Lock()
 	defer bt.mu.Unlock()
 	var err error
+	applyLogs()
 	bt.configure(context.TODO())
 	bt.client, err = torrent.NewClient(bt.config)
 	bt.torrents = make(map[metainfo.Hash]*Torrent)
+	bt.applyLimits()
+	bt.scheduleOnce.Do(func() {
+		go bt.watchSchedule()
+	})
 	InitApiHelper(bt)
 	return err
 }
@@ -77,6 +92,19 @@ func (bt *BTServer) Disconnect() {
 	}
 }
 
+// Apply updates running client by settings not requiring reconnect:
+// cache sizes, rate limits and connections limit, others are read from settings on use
+func (bt *BTServer) Apply() {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+	applyLogs()
+	if bt.client == nil {
+		return
+	}
+	bt.storage.SetCapacity(settings.BTsets.CacheSize)
+	bt.applyLimits()
+}
+
 func (bt *BTServer) configure(ctx context.Context) {
 	blocklist, _ := utils.ReadBlockedIP()
 	bt.config = torrent.NewDefaultClientConfig()
@@ -116,26 +144,25 @@ func (bt *BTServer) configure(ctx context.Context) {
 	 	RequirePreferred: settings.BTsets.ForceEncrypt, //	NE
 		Preferred:        true,                         //	NE
 	} //	NE
-	if settings.BTsets.DownloadRateLimit > 0 {
-		bt.config.DownloadRateLimiter = utils.Limit(settings.BTsets.DownloadRateLimit * 1024)
-	}
-	if settings.BTsets.UploadRateLimit > 0 {
-		bt.config.UploadRateLimiter = utils.Limit(settings.BTsets.UploadRateLimit * 1024)
-	}
+	// limiters are always set to be changed on running client
+	bt.config.DownloadRateLimiter = utils.Limit(settings.BTsets.DownloadRateLimit * 1024)
+	bt.config.UploadRateLimiter = utils.Limit(settings.BTsets.UploadRateLimit * 1024)
+	bt.downloadLimit = settings.BTsets.DownloadRateLimit
+	bt.uploadLimit = settings.BTsets.UploadRateLimit
 	if settings.TorAddr != "" {
-		log.Println("Set listen addr", settings.TorAddr)
+		logger.Info("set listen addr", "addr", settings.TorAddr)
 		bt.config.SetListenAddr(settings.TorAddr)
 	} else {
 		if settings.BTsets.PeersListenPort > 0 {
-			log.Println("Set listen port", settings.BTsets.PeersListenPort)
+			logger.Info("set listen port", "port", settings.BTsets.PeersListenPort)
 			bt.config.ListenPort = settings.BTsets.PeersListenPort
 		} else {
-			log.Println("Set listen port to random autoselect (0)")
+			logger.Info("set listen port to random autoselect (0)")
 			bt.config.ListenPort = 0
 		}
 	}
 
-	log.Println("Client config:", settings.BTsets)
+	logger.Info("client config", "settings", settings.BTsets)
 
 	var err error
 
@@ -148,14 +175,14 @@ func (bt *BTServer) configure(ctx context.Context) {
 	if bt.config.PublicIp4 == nil {
 		bt.config.PublicIp4, err = publicip.Get4(ctx)
 		if err != nil {
-			log.Printf("error getting public ipv4 address: %v", err)
+			logger.Warn("get public ipv4 address", "err", err)
 		}
 	}
 	if bt.config.PublicIp4.To4() == nil { // possible IPv6 from publicip.Get4(ctx)
 		bt.config.PublicIp4 = nil
 	}
 	if bt.config.PublicIp4 != nil {
-		log.Println("PublicIp4:", bt.config.PublicIp4)
+		logger.Info("public ipv4", "ip", bt.config.PublicIp4)
 	}
 
 	// set public IPv6
@@ -167,14 +194,14 @@ func (bt *BTServer) configure(ctx context.Context) {
 	if bt.config.PublicIp6 == nil && settings.BTsets.EnableIPv6 {
 		bt.config.PublicIp6, err = publicip.Get6(ctx)
 		if err != nil {
-			log.Printf("error getting public ipv6 address: %v", err)
+			logger.Warn("get public ipv6 address", "err", err)
 		}
 	}
 	if bt.config.PublicIp6.To16() == nil { // just 4 sure it's valid IPv6
 		bt.config.PublicIp6 = nil
 	}
 	if bt.config.PublicIp6 != nil {
-		log.Println("PublicIp6:", bt.config.PublicIp6)
+		logger.Info("public ipv6", "ip", bt.config.PublicIp6)
 	}
 }
 
@@ -2,10 +2,13 @@ package torr
 
 import (
 	"encoding/json"
+	"strconv"
 
+	"server/media"
 	"server/settings"
 	"server/torr/state"
 	"server/torr/utils"
+	utils2 "server/utils"
 
 	"github.com/anacrolix/torrent/metainfo"
 )
@@ -17,30 +20,46 @@ type tsFiles struct {
 }
 
 func AddTorrentDB(torr *Torrent) {
+	// files are taken before lock, as status locks torrent
+	var files []*state.TorrentFileStat
+	torr.muTorrent.Lock()
+	noFiles := len(getDataFiles(torr.Data)) == 0
+	torr.muTorrent.Unlock()
+	if noFiles {
+		files = torr.Status().FileStats
+	}
+
+	// data is changed by probe under muTorrent, so torrent is saved from snapshot
 	t := new(settings.TorrentDB)
-	t.TorrentSpec = torr.TorrentSpec
-	t.Title = torr.Title
-	t.Category = torr.Category
-	if torr.Data == "" {
-		files := new(tsFiles)
-		files.TorrServer.Files = torr.Status().FileStats
-		buf, err := json.Marshal(files)
-		if err == nil {
-			t.Data = string(buf)
-			torr.Data = t.Data
-		}
+	torr.muTorrent.Lock()
+	if data, err := setDataFiles(torr.Data, files); err == nil {
+		torr.Data = data
 	} else {
-		t.Data = torr.Data
+		logger.Debug("save files to data", "hash", torr.Hash().HexString(), "err", err)
 	}
-	if utils.CheckImgUrl(torr.Poster) {
-		t.Poster = torr.Poster
+	if data, err := setDataRelease(torr.Data, torr.release()); err == nil {
+		torr.Data = data
+	} else {
+		logger.Debug("save release to data", "hash", torr.Hash().HexString(), "err", err)
 	}
+	t.TorrentSpec = torr.TorrentSpec
+	t.Title = torr.Title
+	t.Category = torr.Category
+	t.Data = torr.Data
+	t.Poster = torr.Poster
 	t.Size = torr.Size
+	t.DownloadRateLimit = torr.DownloadRateLimit
+	t.UploadRateLimit = torr.UploadRateLimit
+	// don't override timestamp from DB on edit
+	t.Timestamp = torr.Timestamp // time.Now().Unix()
+	torr.muTorrent.Unlock()
+
+	if !utils.CheckImgUrl(t.Poster) {
+		t.Poster = ""
+	}
 	if t.Size == 0 && torr.Torrent != nil {
 		t.Size = torr.Torrent.Length()
 	}
-	// don't override timestamp from DB on edit
-	t.Timestamp = torr.Timestamp // time.Now().Unix()
 
 	settings.AddTorrent(t)
 }
@@ -57,6 +76,8 @@ func GetTorrentDB(hash metainfo.Hash) *Torrent {
 			torr.Timestamp = db.Timestamp
 			torr.Size = db.Size
 			torr.Data = db.Data
+			torr.DownloadRateLimit = db.DownloadRateLimit
+			torr.UploadRateLimit = db.UploadRateLimit
 			torr.Stat = state.TorrentInDB
 			return torr
 		}
@@ -80,8 +101,156 @@ func ListTorrentsDB() map[metainfo.Hash]*Torrent {
 		torr.Timestamp = db.Timestamp
 		torr.Size = db.Size
 		torr.Data = db.Data
+		torr.DownloadRateLimit = db.DownloadRateLimit
+		torr.UploadRateLimit = db.UploadRateLimit
 		torr.Stat = state.TorrentInDB
 		ret[torr.TorrentSpec.InfoHash] = torr
 	}
 	return ret
 }
+
+// getDataFiles returns files saved in torrent data
+func getDataFiles(data string) []*state.TorrentFileStat {
+	if data == "" {
+		return nil
+	}
+	files := new(tsFiles)
+	if err := json.Unmarshal([]byte(data), files); err != nil {
+		return nil
+	}
+	return files.TorrServer.Files
+}
+
+// setDataFiles stores files in torrent data if data has none, other fields of data are kept
+func setDataFiles(data string, files []*state.TorrentFileStat) (string, error) {
+	if len(files) == 0 || len(getDataFiles(data)) > 0 {
+		return data, nil
+	}
+	return updateData(data, func(ts map[string]json.RawMessage) error {
+		var err error
+		ts["Files"], err = json.Marshal(files)
+		return err
+	})
+}
+
+// getDataProbe returns cached probe of file from torrent data
+func getDataProbe(data string, fileID int) *media.ProbeData {
+	if data == "" {
+		return nil
+	}
+	var probes struct {
+		TorrServer struct {
+			FFProbe map[string]*media.ProbeData `json:"FFProbe"`
+		} `json:"TorrServer"`
+	}
+	if err := json.Unmarshal([]byte(data), &probes); err != nil {
+		return nil
+	}
+	return probes.TorrServer.FFProbe[strconv.Itoa(fileID)]
+}
+
+// setDataProbe stores probe of file in torrent data, other fields of data are kept
+func setDataProbe(data string, fileID int, pd *media.ProbeData) (string, error) {
+	return updateData(data, func(ts map[string]json.RawMessage) error {
+		probes := map[string]*media.ProbeData{}
+		if buf, ok := ts["FFProbe"]; ok {
+			if err := json.Unmarshal(buf, &probes); err != nil {
+				return err
+			}
+		}
+		probes[strconv.Itoa(fileID)] = pd
+
+		var err error
+		ts["FFProbe"], err = json.Marshal(probes)
+		return err
+	})
+}
+
+// getDataRelease returns release info saved in torrent data
+func getDataRelease(data string) *state.Release {
+	if data == "" {
+		return nil
+	}
+	var rel struct {
+		TorrServer struct {
+			Release *state.Release `json:"Release"`
+		} `json:"TorrServer"`
+	}
+	if err := json.Unmarshal([]byte(data), &rel); err != nil {
+		return nil
+	}
+	return rel.TorrServer.Release
+}
+
+// setDataRelease stores release info of torrent and its media files in torrent data,
+// parsed info is replaced when it differs, edited info is kept
+func setDataRelease(data string, rel *state.Release) (string, error) {
+	if rel == nil {
+		return data, nil
+	}
+	return updateData(data, func(ts map[string]json.RawMessage) error {
+		var err error
+		var old *state.Release
+		if buf, ok := ts["Release"]; ok {
+			if err = json.Unmarshal(buf, &old); err != nil {
+				return err
+			}
+		}
+		if old == nil || !old.Edited && *old != *rel {
+			if ts["Release"], err = json.Marshal(rel); err != nil {
+				return err
+			}
+		}
+		buf, ok := ts["Files"]
+		if !ok {
+			return nil
+		}
+		var files []*state.TorrentFileStat
+		if err = json.Unmarshal(buf, &files); err != nil {
+			return err
+		}
+		changed := false
+		for _, f := range files {
+			if f == nil || f.Release != nil && f.Release.Edited || utils2.GetMimeType(f.Path) == "*/*" {
+				continue
+			}
+			if fr := utils2.FileRelease(rel, f.Path); f.Release == nil || *f.Release != *fr {
+				f.Release = fr
+				changed = true
+			}
+		}
+		if changed {
+			ts["Files"], err = json.Marshal(files)
+		}
+		return err
+	})
+}
+
+// updateData changes TorrServer object of torrent data by fn, other fields of data are kept
+func updateData(data string, fn func(ts map[string]json.RawMessage) error) (string, error) {
+	root := map[string]json.RawMessage{}
+	if data != "" {
+		if err := json.Unmarshal([]byte(data), &root); err != nil {
+			return "", err
+		}
+	}
+	ts := map[string]json.RawMessage{}
+	if buf, ok := root["TorrServer"]; ok {
+		if err := json.Unmarshal(buf, &ts); err != nil {
+			return "", err
+		}
+	}
+	if err := fn(ts); err != nil {
+		return "", err
+	}
+
+	var err error
+	if root["TorrServer"], err = json.Marshal(ts); err != nil {
+		return "", err
+	}
+	buf, err := json.Marshal(root)
+	if err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
@@ -0,0 +1,188 @@
+package torr
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"server/media"
+	"server/settings"
+	"server/torr/state"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// setTestDB opens settings in temp dir, settings are loaded again as migration requires
+func setTestDB(t *testing.T) {
+	old := settings.BTsets
+	t.Cleanup(func() {
+		settings.CloseDB()
+		settings.BTsets = old
+	})
+	settings.Path = t.TempDir()
+	settings.BTsets = nil
+	settings.InitSets(false)
+}
+
+func TestSetDataRelease(t *testing.T) {
+	if data, err := setDataRelease(`{"x":1}`, nil); err != nil || data != `{"x":1}` {
+		t.Errorf("nil release changed data %q %v", data, err)
+	}
+
+	rel := &state.Release{Title: "Breaking Bad", Season: 2, Resolution: "720p"}
+	data, err := setDataRelease(`{"x":1,"TorrServer":{"Files":[{"id":1,"path":"BB/Breaking.Bad.S02E03.mkv"},{"id":2,"path":"BB/info.nfo"}]}}`, rel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := getDataFiles(data)
+	if got := getDataRelease(data); got == nil || *got != *rel {
+		t.Errorf("release %+v, want %+v", got, rel)
+	}
+	if len(files) != 2 || files[0].Release == nil || files[0].Release.Episode != 3 || files[0].Release.Title != "Breaking Bad" || files[1].Release != nil {
+		t.Errorf("wrong file releases %+v %+v", files[0].Release, files[1].Release)
+	}
+	var root map[string]any
+	if json.Unmarshal([]byte(data), &root) != nil || root["x"] != 1.0 {
+		t.Errorf("other data is lost %s", data)
+	}
+
+	// parsed info follows title
+	renamed := &state.Release{Title: "Breaking Bad Remastered", Season: 2}
+	if data, err = setDataRelease(data, renamed); err != nil {
+		t.Fatal(err)
+	}
+	if got := getDataRelease(data); got == nil || *got != *renamed {
+		t.Errorf("release %+v, want %+v", got, renamed)
+	}
+	if files = getDataFiles(data); files[0].Release.Title != renamed.Title {
+		t.Errorf("file release is not updated %+v", files[0].Release)
+	}
+
+	// edited info is kept
+	edited := `{"TorrServer":{"Release":{"title":"Fixed","edited":true},"Files":[{"id":1,"path":"a.S01E01.mkv","release":{"title":"Pilot","edited":true}}]}}`
+	if data, err = setDataRelease(edited, renamed); err != nil {
+		t.Fatal(err)
+	}
+	if got := getDataRelease(data); got == nil || got.Title != "Fixed" {
+		t.Errorf("edited release is replaced %+v", got)
+	}
+	if files = getDataFiles(data); files[0].Release.Title != "Pilot" {
+		t.Errorf("edited file release is replaced %+v", files[0].Release)
+	}
+}
+
+func TestSetDataFiles(t *testing.T) {
+	files := []*state.TorrentFileStat{{Id: 1, Path: "a.mkv", Length: 10}}
+	probed := `{"TorrServer":{"FFProbe":{"1":{}}}}`
+	data, err := setDataFiles(probed, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := getDataFiles(data); len(got) != 1 || *got[0] != *files[0] || getDataProbe(data, 1) == nil {
+		t.Errorf("files are not merged to probed data %s", data)
+	}
+
+	saved := `{"TorrServer":{"Files":[{"id":1,"path":"b.mkv"}]}}`
+	if data, _ = setDataFiles(saved, files); data != saved {
+		t.Errorf("saved files are replaced %s", data)
+	}
+	if data, _ = setDataFiles(probed, nil); data != probed {
+		t.Errorf("data is changed without files %s", data)
+	}
+	if data, _ = setDataFiles(`{"TorrServer":{"Files":null}}`, files); len(getDataFiles(data)) != 1 {
+		t.Errorf("empty files are not replaced %s", data)
+	}
+}
+
+func TestTorrentRelease(t *testing.T) {
+	tor := &Torrent{TorrentSpec: &torrent.TorrentSpec{DisplayName: "Movie.2020.1080p.x264-GRP"}}
+	if rel := tor.Release(); rel == nil || rel.Title != "Movie" || rel.Year != 2020 || rel.Group != "GRP" {
+		t.Errorf("wrong release %+v", rel)
+	}
+
+	// saved parsed info is not used
+	tor.Data, _ = setDataRelease("", tor.Release())
+	tor.TorrentSpec.DisplayName = "Film.2021"
+	if rel := tor.Release(); rel == nil || rel.Title != "Film" || rel.Year != 2021 {
+		t.Errorf("release does not follow name %+v", rel)
+	}
+
+	tor.Data = `{"TorrServer":{"Release":{"title":"Edited","edited":true}}}`
+	if rel := tor.Release(); rel == nil || rel.Title != "Edited" {
+		t.Errorf("edited release is not used %+v", rel)
+	}
+
+	tor = &Torrent{TorrentSpec: &torrent.TorrentSpec{}}
+	if rel := tor.Release(); rel != nil {
+		t.Errorf("release of empty name %+v", rel)
+	}
+}
+
+func TestAddTorrentDB(t *testing.T) {
+	setTestDB(t)
+	tor := &Torrent{
+		TorrentSpec: &torrent.TorrentSpec{InfoHash: metainfo.Hash{1}, DisplayName: "Show.S01.1080p"},
+		Title:       "Show S01",
+		Data:        `{"TorrServer":{"Files":[{"id":1,"path":"Show.S01E01.mkv"}]}}`,
+		Timestamp:   100,
+	}
+
+	// probe changes data while torrent is saved
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := range 50 {
+			tor.muTorrent.Lock()
+			tor.Data, _ = setDataProbe(tor.Data, i, &media.ProbeData{})
+			tor.muTorrent.Unlock()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for range 50 {
+			AddTorrentDB(tor)
+		}
+	}()
+	wg.Wait()
+	AddTorrentDB(tor)
+
+	db := GetTorrentDB(tor.Hash())
+	if db == nil || db.Title != "Show S01" || db.Timestamp != 100 || db.Data != tor.Data {
+		t.Fatalf("wrong saved torrent %+v", db)
+	}
+	if rel := getDataRelease(db.Data); rel == nil || rel.Title != "Show" || rel.Season != 1 {
+		t.Errorf("wrong saved release %+v", rel)
+	}
+	if files := getDataFiles(db.Data); len(files) != 1 || files[0].Release == nil || files[0].Release.Episode != 1 {
+		t.Errorf("wrong saved files %+v", files)
+	}
+	if getDataProbe(db.Data, 49) == nil {
+		t.Error("probe is lost")
+	}
+}
+
+func TestDataProbeConcurrent(t *testing.T) {
+	tor := &Torrent{}
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := range 50 {
+			tor.muTorrent.Lock()
+			tor.Data, _ = setDataProbe(tor.Data, i, &media.ProbeData{})
+			tor.muTorrent.Unlock()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := range 50 {
+			tor.dataProbe(i)
+		}
+	}()
+	wg.Wait()
+	if tor.dataProbe(49) == nil {
+		t.Error("probe is not found")
+	}
+}
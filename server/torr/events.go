@@ -0,0 +1,98 @@
+package torr
+
+import (
+	"sync"
+
+	"server/torr/state"
+	cacheSt "server/torr/storage/state"
+)
+
+// Event is torrent state sent to subscribers by progress ticker
+type Event struct {
+	Hash   string
+	Status *state.TorrentStatus
+	Cache  *cacheSt.CacheState // nil if not subscribed
+}
+
+type Subscriber struct {
+	C      chan *Event
+	hashes map[string]struct{} // empty for all torrents
+	cache  bool
+}
+
+var (
+	subscribers   = make(map[*Subscriber]struct{})
+	muSubscribers sync.RWMutex
+)
+
+// Subscribe returns subscriber to events of hashes or of all torrents if hashes are empty
+func Subscribe(hashes []string, cache bool) *Subscriber {
+	s := &Subscriber{
+		C:      make(chan *Event, 64),
+		hashes: make(map[string]struct{}, len(hashes)),
+		cache:  cache,
+	}
+	for _, h := range hashes {
+		s.hashes[h] = struct{}{}
+	}
+	muSubscribers.Lock()
+	subscribers[s] = struct{}{}
+	muSubscribers.Unlock()
+	return s
+}
+
+func Unsubscribe(s *Subscriber) {
+	muSubscribers.Lock()
+	delete(subscribers, s)
+	muSubscribers.Unlock()
+}
+
+func (s *Subscriber) wants(hash string) bool {
+	if len(s.hashes) == 0 {
+		return true
+	}
+	_, ok := s.hashes[hash]
+	return ok
+}
+
+// subscribed reports if anyone wants events and cache state of hash
+func subscribed(hash string) (status, cache bool) {
+	muSubscribers.RLock()
+	defer muSubscribers.RUnlock()
+	for s := range subscribers {
+		if s.wants(hash) {
+			status = true
+			cache = cache || s.cache
+		}
+	}
+	return
+}
+
+// publishEvent sends state of torrent to subscribers, slow subscribers miss events
+func (t *Torrent) publishEvent() {
+	hash := t.Hash().HexString()
+	status, cache := subscribed(hash)
+	if !status {
+		return
+	}
+	ev := &Event{Hash: hash, Status: t.Status()}
+	if cache && t.cache != nil && t.Torrent != nil {
+		ev.Cache = t.cache.GetState()
+	}
+
+	muSubscribers.RLock()
+	defer muSubscribers.RUnlock()
+	for s := range subscribers {
+		if !s.wants(hash) {
+			continue
+		}
+		e := ev
+		if !s.cache && ev.Cache != nil {
+			e = &Event{Hash: ev.Hash, Status: ev.Status}
+		}
+		select {
+		case s.C <- e:
+		default:
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package torr
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"server/media"
+	"server/metrics"
+	sets "server/settings"
+)
+
+const (
+	HLSTypeTS   = "ts"
+	HLSTypeFMP4 = "fmp4"
+
+	tsPacketSize     = 188
+	tsSegmentPackets = 22310               // ~4 MB segment
+	tsAssumedBitRate = 8 * 1000 * 1000 / 8 // bytes per second when duration unknown
+)
+
+type HLSSegment struct {
+	Offset   int64
+	Length   int64
+	Duration float64
+}
+
+type HLSIndex struct {
+	Type     string
+	Init     *HLSSegment // fmp4 initialization section (ftyp+moov)
+	Segments []*HLSSegment
+
+	// ts segments are moved to keyframes on demand, nominal to keyframe offset
+	media  *media.Index
+	bounds map[int64]int64
+	mu     sync.Mutex
+}
+
+// Playlist returns VOD media playlist with relative segment urls, query is added to urls if not empty
+func (h *HLSIndex) Playlist(query string) string {
+	if query != "" {
+		query = "?" + query
+	}
+	target := 1.0
+	for _, s := range h.Segments {
+		target = math.Max(target, s.Duration)
+	}
+	var sb strings.Builder
+	sb.WriteString("#EXTM3U\n")
+	if h.Type == HLSTypeFMP4 {
+		sb.WriteString("#EXT-X-VERSION:7\n")
+	} else {
+		sb.WriteString("#EXT-X-VERSION:3\n")
+	}
+	sb.WriteString("#EXT-X-TARGETDURATION:" + strconv.Itoa(int(math.Ceil(target))) + "\n")
+	sb.WriteString("#EXT-X-MEDIA-SEQUENCE:0\n")
+	sb.WriteString("#EXT-X-PLAYLIST-TYPE:VOD\n")
+	if h.Type == HLSTypeFMP4 {
+		// fmp4 segments start at sidx keyframes, ts ones may start between keyframes
+		sb.WriteString("#EXT-X-INDEPENDENT-SEGMENTS\n")
+	}
+	if h.Init != nil {
+		sb.WriteString("#EXT-X-MAP:URI=\"init.mp4" + query + "\"\n")
+	}
+	for i, s := range h.Segments {
+		sb.WriteString(fmt.Sprintf("#EXTINF:%.3f,\n", s.Duration))
+		sb.WriteString(h.SegmentName(i) + query + "\n")
+	}
+	sb.WriteString("#EXT-X-ENDLIST\n")
+	return sb.String()
+}
+
+func (h *HLSIndex) SegmentName(i int) string {
+	if h.Type == HLSTypeFMP4 {
+		return strconv.Itoa(i) + ".m4s"
+	}
+	return strconv.Itoa(i) + ".ts"
+}
+
+func (h *HLSIndex) Segment(name string) *HLSSegment {
+	if name == "init.mp4" {
+		return h.Init
+	}
+	ind, err := strconv.Atoi(strings.TrimSuffix(name, filepath.Ext(name)))
+	if err != nil || ind < 0 || ind >= len(h.Segments) || h.SegmentName(ind) != name {
+		return nil
+	}
+	return h.Segments[ind]
+}
+
+func hlsType(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".ts", ".m2ts", ".mts":
+		return HLSTypeTS
+	case ".mp4", ".m4v":
+		return HLSTypeFMP4
+	}
+	return ""
+}
+
+func (t *Torrent) HLS(fileID int) (*HLSIndex, error) {
+	t.muMedia.Lock()
+	if idx, ok := t.hls[fileID]; ok {
+		t.muMedia.Unlock()
+		return idx, nil
+	}
+	t.muMedia.Unlock()
+
+	file := t.findFileIndex(fileID)
+	if file == nil {
+		return nil, fmt.Errorf("file with id %v not found", fileID)
+	}
+
+	var idx *HLSIndex
+	switch hlsType(file.Path()) {
+	case HLSTypeTS:
+		mi, err := t.MediaIndex(fileID)
+		if err != nil {
+			return nil, err
+		}
+		idx = tsIndex(mi)
+	case HLSTypeFMP4:
+		mi, err := t.MediaIndex(fileID)
+		if err != nil {
+			return nil, err
+		}
+		if idx, err = fmp4Index(mi); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("file %v is not MPEG-TS or fragmented MP4", file.Path())
+	}
+
+	t.muMedia.Lock()
+	if t.hls == nil {
+		t.hls = make(map[int]*HLSIndex)
+	}
+	t.hls[fileID] = idx
+	t.muMedia.Unlock()
+	return idx, nil
+}
+
+// tsIndex splits transport stream by packet aligned byte ranges, which are moved to keyframes when served,
+// segment duration is proportional to its size
+func tsIndex(mi *media.Index) *HLSIndex {
+	idx := &HLSIndex{Type: HLSTypeTS, media: mi, bounds: make(map[int64]int64)}
+	byteRate := float64(tsAssumedBitRate)
+	if mi.Duration > 0 {
+		byteRate = float64(mi.Size) / mi.Duration
+	}
+	segLen := int64(tsPacketSize * tsSegmentPackets)
+	for off := int64(0); off < mi.Size; off += segLen {
+		l := min(segLen, mi.Size-off)
+		idx.Segments = append(idx.Segments, &HLSSegment{
+			Offset:   off,
+			Length:   l,
+			Duration: float64(l) / byteRate,
+		})
+	}
+	return idx
+}
+
+// fmp4Index uses init section and fragments indexed by sidx
+func fmp4Index(mi *media.Index) (*HLSIndex, error) {
+	if !mi.Fragmented {
+		return nil, errors.New("mp4 is not fragmented")
+	}
+	if len(mi.Keyframes) == 0 {
+		return nil, errors.New("mp4 has no sidx index")
+	}
+	idx := &HLSIndex{Type: HLSTypeFMP4}
+	idx.Init = &HLSSegment{Offset: 0, Length: mi.InitSize}
+	for i, kf := range mi.Keyframes {
+		end, endTime := mi.DataEnd, mi.Duration
+		if i+1 < len(mi.Keyframes) {
+			end, endTime = mi.Keyframes[i+1].Offset, mi.Keyframes[i+1].Time
+		}
+		idx.Segments = append(idx.Segments, &HLSSegment{
+			Offset:   kf.Offset,
+			Length:   end - kf.Offset,
+			Duration: max(endTime-kf.Time, 0),
+		})
+	}
+	return idx, nil
+}
+
+// tsBoundary returns offset of first keyframe in segment length after nominal boundary,
+// boundary itself if there is no keyframe, so segments stay in order
+func (h *HLSIndex) tsBoundary(r io.ReadSeeker, off int64) (int64, error) {
+	if off == 0 || off >= h.media.Size {
+		return off, nil
+	}
+	h.mu.Lock()
+	b, ok := h.bounds[off]
+	h.mu.Unlock()
+	if ok {
+		return b, nil
+	}
+	kf, ok, err := h.media.TSKeyframe(r, off, off+tsPacketSize*tsSegmentPackets)
+	if err != nil {
+		return 0, err
+	}
+	b = off
+	if ok {
+		b = kf.Offset
+	}
+	h.mu.Lock()
+	h.bounds[off] = b
+	h.mu.Unlock()
+	return b, nil
+}
+
+// alignTS returns segment moved to keyframes of its and next segment boundaries
+func (h *HLSIndex) alignTS(r io.ReadSeeker, seg *HLSSegment) (*HLSSegment, error) {
+	start, err := h.tsBoundary(r, seg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	end, err := h.tsBoundary(r, seg.Offset+seg.Length)
+	if err != nil {
+		return nil, err
+	}
+	return &HLSSegment{Offset: start, Length: end - start, Duration: seg.Duration}, nil
+}
+
+// ServeHLSSegment writes file byte range of segment through torrent reader,
+// nothing is written to response if error happens before data
+func (t *Torrent) ServeHLSSegment(fileID int, idx *HLSIndex, seg *HLSSegment, contentType string, req *http.Request, resp http.ResponseWriter) error {
+	file := t.findFileIndex(fileID)
+	if file == nil {
+		return fmt.Errorf("file with id %v not found", fileID)
+	}
+	reader := t.NewReader(file)
+	if reader == nil {
+		return errors.New("torrent closed")
+	}
+	defer t.CloseReader(reader)
+	if sets.BTsets.ResponsiveMode {
+		reader.SetResponsive()
+	}
+	if idx.Type == HLSTypeTS {
+		reader.SetReadahead(0)
+		var err error
+		if seg, err = idx.alignTS(reader, seg); err != nil {
+			return err
+		}
+	}
+	if seg.Offset+seg.Length > file.Length() {
+		return errors.New("segment out of file")
+	}
+	reader.SetReadahead(seg.Length)
+	if _, err := reader.Seek(seg.Offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	resp.Header().Set("Content-Type", contentType)
+	resp.Header().Set("Content-Length", strconv.FormatInt(seg.Length, 10))
+	resp.Header().Set("Cache-Control", "max-age=86400")
+	if req.Method == http.MethodHead {
+		return nil
+	}
+
+	streams.Add(1)
+	defer streams.Done()
+	sw := metrics.StartStream(resp)
+	sw.SetOffset(seg.Offset)
+	_, err := io.CopyN(sw, reader, seg.Length)
+	sw.End()
+	if err != nil {
+		logger.Debug("hls segment", "hash", t.Hash().HexString(), "path", file.Path(), "err", err)
+	}
+	t.saveSession(fileID, req, sw)
+	return err
+}
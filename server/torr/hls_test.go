@@ -0,0 +1,91 @@
+package torr
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"server/media"
+)
+
+// tsPacket is h264 video packet on pid 0x100 with pcr, random access indicator makes it keyframe
+func tsPacket(keyframe bool, pcrSec int64) []byte {
+	pkt := bytes.Repeat([]byte{0xFF}, tsPacketSize)
+	pkt[0], pkt[1], pkt[2], pkt[3] = 0x47, 0x41, 0x00, 0x30
+	flags := byte(0x10)
+	if keyframe {
+		flags |= 0x40
+	}
+	base := pcrSec * 90000
+	copy(pkt[4:], []byte{7, flags, byte(base >> 25), byte(base >> 17), byte(base >> 9), byte(base >> 1), byte(base&1)<<7 | 0x7E, 0})
+	return pkt
+}
+
+func tsFiller() []byte {
+	pkt := bytes.Repeat([]byte{0xFF}, tsPacketSize)
+	pkt[0], pkt[1], pkt[2], pkt[3] = 0x47, 0x01, 0x00, 0x10
+	return pkt
+}
+
+// testTS has keyframes at packet 2 and in middle of second nominal segment, third one has none
+func testTS() ([]byte, int64) {
+	pat := []byte{0x47, 0x40, 0x00, 0x10, 0, 0x00, 0xB0, 13, 0, 1, 0xC1, 0, 0, 0, 1, 0xF0, 0x00, 0, 0, 0, 0}
+	pmt := []byte{0x47, 0x50, 0x00, 0x10, 0, 0x02, 0xB0, 18, 0, 1, 0xC1, 0, 0, 0xE1, 0x00, 0xF0, 0,
+		0x1B, 0xE1, 0x00, 0xF0, 0, 0, 0, 0, 0}
+	var buf bytes.Buffer
+	for _, p := range [][]byte{pat, pmt} {
+		buf.Write(append(p, bytes.Repeat([]byte{0xFF}, tsPacketSize-len(p))...))
+	}
+	buf.Write(tsPacket(true, 0))
+	kf := int64(tsSegmentPackets*3/2) * tsPacketSize
+	for buf.Len() < int(kf) {
+		buf.Write(tsFiller())
+	}
+	buf.Write(tsPacket(true, 10))
+	for buf.Len() < 2*tsSegmentPackets*tsPacketSize+100*tsPacketSize {
+		buf.Write(tsFiller())
+	}
+	buf.Write(tsPacket(false, 20))
+	return buf.Bytes(), kf
+}
+
+func TestTSIndexKeyframeSegments(t *testing.T) {
+	data, kf := testTS()
+	r := bytes.NewReader(data)
+	mi, err := media.ParseIndex(r, int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx := tsIndex(mi)
+	if len(idx.Segments) != 3 {
+		t.Fatalf("%d segments, want 3", len(idx.Segments))
+	}
+
+	var got []*HLSSegment
+	for _, seg := range idx.Segments {
+		s, err := idx.alignTS(r, seg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, s)
+	}
+	// second boundary has no keyframe in segment length after it, so it stays at byte boundary
+	segLen := int64(tsSegmentPackets * tsPacketSize)
+	want := [][2]int64{{0, kf}, {kf, 2 * segLen}, {2 * segLen, int64(len(data))}}
+	for i, s := range got {
+		if s.Offset != want[i][0] || s.Offset+s.Length != want[i][1] {
+			t.Errorf("segment %d is [%d, %d), want %v", i, s.Offset, s.Offset+s.Length, want[i])
+		}
+	}
+
+	pl := idx.Playlist("")
+	if strings.Contains(pl, "#EXT-X-INDEPENDENT-SEGMENTS") {
+		t.Error("ts playlist claims independent segments")
+	}
+	if !strings.Contains(pl, "\n2.ts\n#EXT-X-ENDLIST") {
+		t.Errorf("wrong playlist\n%s", pl)
+	}
+	if pl = idx.Playlist("exp=1&sig=x"); !strings.Contains(pl, "\n0.ts?exp=1&sig=x\n") || !strings.Contains(pl, "\n2.ts?exp=1&sig=x\n#EXT-X-ENDLIST") {
+		t.Errorf("segments are not signed\n%s", pl)
+	}
+}
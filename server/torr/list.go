@@ -0,0 +1,93 @@
+package torr
+
+import (
+	"sort"
+	"strings"
+
+	sets "server/settings"
+	"server/torr/state"
+	"server/utils"
+)
+
+// ListQuery filters, sorts and pages torrents list, zero fields are not checked
+type ListQuery struct {
+	Category string
+	Title    string // case insensitive substring of title
+	Stat     *state.TorrentStat
+	Sort     string // timestamp (def), size, title, viewed
+	Order    string // asc, desc, def asc for title and desc for others
+	Offset   int
+	Limit    int
+}
+
+// QueryTorrents returns page of torrents matching query and count of all matching torrents
+func QueryTorrents(q *ListQuery) ([]*Torrent, int) {
+	var list []*Torrent
+	title := strings.ToLower(q.Title)
+	for _, t := range ListTorrent() {
+		if q.Category != "" && !strings.EqualFold(t.Category, q.Category) {
+			continue
+		}
+		if title != "" && !strings.Contains(strings.ToLower(t.Title), title) {
+			continue
+		}
+		if q.Stat != nil && t.Stat != *q.Stat {
+			continue
+		}
+		list = append(list, t)
+	}
+
+	// list is sorted by timestamp desc already
+	desc := q.Order != "asc"
+	if q.Sort == "title" {
+		desc = q.Order == "desc"
+	}
+	switch q.Sort {
+	case "size":
+		sort.SliceStable(list, func(i, j int) bool {
+			if desc {
+				return list[i].size() > list[j].size()
+			}
+			return list[i].size() < list[j].size()
+		})
+	case "title":
+		sort.SliceStable(list, func(i, j int) bool {
+			if desc {
+				return utils.CompareStrings(strings.ToLower(list[j].Title), strings.ToLower(list[i].Title))
+			}
+			return utils.CompareStrings(strings.ToLower(list[i].Title), strings.ToLower(list[j].Title))
+		})
+	case "viewed":
+		viewed := sets.LastViewed()
+		sort.SliceStable(list, func(i, j int) bool {
+			vi, vj := viewed[list[i].Hash().HexString()], viewed[list[j].Hash().HexString()]
+			if desc {
+				return vi > vj
+			}
+			return vi < vj
+		})
+	default:
+		if !desc {
+			sort.SliceStable(list, func(i, j int) bool {
+				return list[i].Timestamp < list[j].Timestamp
+			})
+		}
+	}
+
+	total := len(list)
+	if q.Offset > 0 {
+		list = list[min(q.Offset, len(list)):]
+	}
+	if q.Limit > 0 && len(list) > q.Limit {
+		list = list[:q.Limit]
+	}
+	return list, total
+}
+
+// size returns size of torrent from info, saved size otherwise
+func (t *Torrent) size() int64 {
+	if t.Torrent != nil && t.Torrent.Info() != nil {
+		return t.Torrent.Length()
+	}
+	return t.Size
+}
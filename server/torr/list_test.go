@@ -0,0 +1,66 @@
+package torr
+
+import (
+	"slices"
+	"testing"
+
+	"server/settings"
+	"server/torr/state"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+func TestQueryTorrents(t *testing.T) {
+	setTestDB(t)
+	saved := []*Torrent{
+		{Title: "Alpha 10", Category: "movie", Size: 300, Timestamp: 100},
+		{Title: "alpha 9", Category: "movie", Size: 100, Timestamp: 200},
+		{Title: "Beta", Category: "tv", Size: 200, Timestamp: 300},
+	}
+	for i, tor := range saved {
+		tor.TorrentSpec = &torrent.TorrentSpec{InfoHash: metainfo.Hash{byte(i + 1)}}
+		tor.Data = "{}"
+		AddTorrentDB(tor)
+	}
+	// running torrent overrides saved one
+	active := &Torrent{TorrentSpec: saved[2].TorrentSpec, Title: "Beta", Category: "tv", Size: 200, Timestamp: 300, Stat: state.TorrentWorking}
+	old := bts
+	t.Cleanup(func() { bts = old })
+	bts = &BTServer{torrents: map[metainfo.Hash]*Torrent{active.Hash(): active}}
+	settings.SetViewed(&settings.Viewed{Hash: saved[0].Hash().HexString(), FileIndex: 1})
+
+	inDB, working := state.TorrentInDB, state.TorrentWorking
+	for _, tc := range []struct {
+		name  string
+		q     ListQuery
+		want  []string
+		total int
+	}{
+		{"newest first", ListQuery{}, []string{"Beta", "alpha 9", "Alpha 10"}, 3},
+		{"oldest first", ListQuery{Order: "asc"}, []string{"Alpha 10", "alpha 9", "Beta"}, 3},
+		{"category", ListQuery{Category: "MOVIE"}, []string{"alpha 9", "Alpha 10"}, 2},
+		{"title", ListQuery{Title: "ALPHA"}, []string{"alpha 9", "Alpha 10"}, 2},
+		{"stat in db", ListQuery{Stat: &inDB}, []string{"alpha 9", "Alpha 10"}, 2},
+		{"stat working", ListQuery{Stat: &working}, []string{"Beta"}, 1},
+		{"size", ListQuery{Sort: "size"}, []string{"Alpha 10", "Beta", "alpha 9"}, 3},
+		{"size asc", ListQuery{Sort: "size", Order: "asc"}, []string{"alpha 9", "Beta", "Alpha 10"}, 3},
+		{"title natural", ListQuery{Sort: "title"}, []string{"alpha 9", "Alpha 10", "Beta"}, 3},
+		{"title desc", ListQuery{Sort: "title", Order: "desc"}, []string{"Beta", "Alpha 10", "alpha 9"}, 3},
+		{"viewed", ListQuery{Sort: "viewed"}, []string{"Alpha 10", "Beta", "alpha 9"}, 3},
+		{"page", ListQuery{Offset: 1, Limit: 1}, []string{"alpha 9"}, 3},
+		{"offset out of list", ListQuery{Offset: 5}, nil, 3},
+	} {
+		list, total := QueryTorrents(&tc.q)
+		var titles []string
+		for _, tor := range list {
+			titles = append(titles, tor.Title)
+		}
+		if !slices.Equal(titles, tc.want) || total != tc.total {
+			t.Errorf("%s: got %v %d, want %v %d", tc.name, titles, total, tc.want, tc.total)
+		}
+	}
+	if list, _ := QueryTorrents(&ListQuery{Stat: &working}); list[0] != active {
+		t.Error("saved torrent is listed instead of running one")
+	}
+}
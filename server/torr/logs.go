@@ -0,0 +1,32 @@
+package torr
+
+import (
+	"path/filepath"
+
+	"server/logs"
+	sets "server/settings"
+)
+
+func logFilePath() string {
+	return filepath.Join(sets.Path, "torrserver.log")
+}
+
+// applyLogs sets log levels of subsystems and log file by settings
+func applyLogs() {
+	path := ""
+	if sets.BTsets.LogFile {
+		path = logFilePath()
+	}
+	if err := logs.Configure(sets.BTsets.LogLevels, sets.BTsets.EnableDebug, path); err != nil {
+		logger.Error("apply log settings", "err", err)
+	}
+}
+
+// SetLogFile starts or stops writing log to rotating file in config dir,
+// file setting is not saved and is restored on settings change
+func SetLogFile(enable bool) error {
+	if enable {
+		return logs.SetFile(logFilePath())
+	}
+	return logs.SetFile("")
+}
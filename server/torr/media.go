@@ -0,0 +1,191 @@
+package torr
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/anacrolix/torrent/metainfo"
+
+	"server/media"
+)
+
+// MediaIndex reads container index of file through torrent reader,
+// fills duration and bitrate of torrent
+func (t *Torrent) MediaIndex(fileID int) (*media.Index, error) {
+	t.muMedia.Lock()
+	if idx, ok := t.media[fileID]; ok {
+		t.muMedia.Unlock()
+		return idx, nil
+	}
+	t.muMedia.Unlock()
+
+	file := t.findFileIndex(fileID)
+	if file == nil {
+		return nil, fmt.Errorf("file with id %v not found", fileID)
+	}
+	reader := t.NewReader(file)
+	if reader == nil {
+		return nil, errors.New("torrent closed")
+	}
+	defer t.CloseReader(reader)
+	reader.SetReadahead(0)
+
+	idx, err := media.ParseIndex(reader, file.Length())
+	if err != nil {
+		logger.Error("read media index", "hash", t.Hash().HexString(), "file", fileID, "path", file.Path(), "err", err)
+		return nil, err
+	}
+
+	t.muMedia.Lock()
+	if t.media == nil {
+		t.media = make(map[int]*media.Index)
+	}
+	t.media[fileID] = idx
+	t.muMedia.Unlock()
+
+	t.muTorrent.Lock()
+	t.DurationSeconds = idx.Duration
+	t.BitRate = idx.BitRateString()
+	t.muTorrent.Unlock()
+	return idx, nil
+}
+
+// Probe reports streams, duration and bitrate of file,
+// result is cached in torrent data and saved to DB if torrent is there
+func (t *Torrent) Probe(fileID int) (*media.ProbeData, error) {
+	if pd := t.dataProbe(fileID); pd != nil {
+		return pd, nil
+	}
+
+	file := t.findFileIndex(fileID)
+	if file == nil {
+		return nil, fmt.Errorf("file with id %v not found", fileID)
+	}
+	reader := t.NewReader(file)
+	if reader == nil {
+		return nil, errors.New("torrent closed")
+	}
+	defer t.CloseReader(reader)
+	reader.SetReadahead(0)
+
+	pd, err := media.Probe(reader, file.Length(), file.Path())
+	if err != nil {
+		logger.Error("probe media", "hash", t.Hash().HexString(), "file", fileID, "path", file.Path(), "err", err)
+		return nil, err
+	}
+
+	t.muTorrent.Lock()
+	t.DurationSeconds = pd.Duration()
+	t.BitRate = pd.Format.BitRate
+	data, err := setDataProbe(t.Data, fileID, pd)
+	if err == nil {
+		t.Data = data
+	}
+	t.muTorrent.Unlock()
+	if err != nil {
+		logger.Error("save probe to torrent data", "hash", t.Hash().HexString(), "file", fileID, "err", err)
+		return pd, nil
+	}
+
+	if torrDb := GetTorrentDB(t.Hash()); torrDb != nil {
+		if data, err := setDataProbe(torrDb.Data, fileID, pd); err == nil {
+			torrDb.Data = data
+			AddTorrentDB(torrDb)
+		}
+	}
+	return pd, nil
+}
+
+// dataProbe returns cached probe of file from torrent data, data is changed by probe under muTorrent
+func (t *Torrent) dataProbe(fileID int) *media.ProbeData {
+	t.muTorrent.Lock()
+	defer t.muTorrent.Unlock()
+	return getDataProbe(t.Data, fileID)
+}
+
+// GetProbe returns cached probe of file from active torrent or DB without loading torrent
+func GetProbe(hashHex string, fileID int) *media.ProbeData {
+	hash := metainfo.NewHashFromHex(hashHex)
+	if tor := bts.GetTorrent(hash); tor != nil {
+		if pd := tor.dataProbe(fileID); pd != nil {
+			return pd
+		}
+	}
+	if tor := GetTorrentDB(hash); tor != nil {
+		return getDataProbe(tor.Data, fileID)
+	}
+	return nil
+}
+
+const maxSubtitleSize = 16 << 20
+
+type subsKey struct{ file, track int }
+
+// Subtitles returns cues of subtitle file (srt, vtt, ass, ssa),
+// if track is not negative cues of embedded text stream of matroska file are returned, they are cached in torrent
+func (t *Torrent) Subtitles(fileID, track int) ([]media.Cue, error) {
+	key := subsKey{fileID, track}
+	if track >= 0 {
+		t.muMedia.Lock()
+		cues, ok := t.subs[key]
+		t.muMedia.Unlock()
+		if ok {
+			return cues, nil
+		}
+	}
+
+	file := t.findFileIndex(fileID)
+	if file == nil {
+		return nil, fmt.Errorf("file with id %v not found", fileID)
+	}
+	reader := t.NewReader(file)
+	if reader == nil {
+		return nil, errors.New("torrent closed")
+	}
+	defer t.CloseReader(reader)
+
+	if track >= 0 {
+		reader.SetReadahead(0)
+		format, err := media.Detect(reader)
+		if err != nil {
+			return nil, err
+		}
+		if format != media.FormatMKV {
+			return nil, errors.New("embedded subtitles are supported in matroska only")
+		}
+		cues, err := media.ReadMKVSubtitles(reader, file.Length(), track)
+		if err != nil {
+			logger.Error("read subtitles", "hash", t.Hash().HexString(), "file", fileID, "track", track, "err", err)
+			return nil, err
+		}
+
+		t.muMedia.Lock()
+		if t.subs == nil {
+			t.subs = make(map[subsKey][]media.Cue)
+		}
+		t.subs[key] = cues
+		t.muMedia.Unlock()
+		return cues, nil
+	}
+
+	var parse func([]byte) []media.Cue
+	switch strings.ToLower(filepath.Ext(file.Path())) {
+	case ".srt", ".vtt":
+		parse = media.ParseSRT
+	case ".ass", ".ssa":
+		parse = media.ParseASS
+	default:
+		return nil, errors.New("file is not subtitles")
+	}
+	if file.Length() > maxSubtitleSize {
+		return nil, errors.New("subtitles file is too big")
+	}
+	buf, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	return parse(buf), nil
+}
@@ -0,0 +1,104 @@
+package torr
+
+import (
+	"server/metrics"
+	sets "server/settings"
+	"server/torr/state"
+	cacheSt "server/torr/storage/state"
+)
+
+type torrentMetrics struct {
+	status     *state.TorrentStatus
+	cache      *cacheSt.CacheState
+	useReaders int
+	labels     []string
+}
+
+// WriteMetrics writes metrics of active torrents, their caches and client limits
+func WriteMetrics(w *metrics.Writer) {
+	var list []*torrentMetrics
+	for _, t := range ListActiveTorrents() {
+		tm := &torrentMetrics{status: t.Status()}
+		if t.Torrent != nil && t.cache != nil {
+			tm.cache = t.cache.GetState()
+			tm.useReaders = t.cache.GetUseReaders()
+		}
+		tm.labels = []string{"hash", tm.status.Hash, "name", tm.status.Name}
+		list = append(list, tm)
+	}
+
+	download, upload := bts.RateLimits()
+	w.Gauge("torrserver_torrents", "Number of active torrents.", float64(len(list)))
+	w.Gauge("torrserver_cache_size_bytes", "Memory cache size shared by all torrents.", float64(sets.BTsets.CacheSize))
+	w.Gauge("torrserver_connections_limit", "Peer connections limit per torrent.", float64(sets.BTsets.ConnectionsLimit))
+	w.Gauge("torrserver_download_rate_limit_bytes", "Global download rate limit in bytes per second, 0 - no limit.", float64(download*1024))
+	w.Gauge("torrserver_upload_rate_limit_bytes", "Global upload rate limit in bytes per second, 0 - no limit.", float64(upload*1024))
+
+	if len(list) == 0 {
+		return
+	}
+
+	gauge := func(name, help string, value func(tm *torrentMetrics) float64) {
+		for _, tm := range list {
+			w.Gauge(name, help, value(tm), tm.labels...)
+		}
+	}
+	counter := func(name, help string, value func(tm *torrentMetrics) float64) {
+		for _, tm := range list {
+			w.Counter(name, help, value(tm), tm.labels...)
+		}
+	}
+
+	for _, tm := range list {
+		w.Gauge("torrserver_torrent_info", "Torrent state, value is always 1.", 1, append(tm.labels, "title", tm.status.Title, "category", tm.status.Category, "stat", tm.status.StatString)...)
+	}
+	gauge("torrserver_torrent_size_bytes", "Size of torrent.", func(tm *torrentMetrics) float64 { return float64(tm.status.TorrentSize) })
+	gauge("torrserver_torrent_loaded_bytes", "Bytes of torrent completed.", func(tm *torrentMetrics) float64 { return float64(tm.status.LoadedSize) })
+	gauge("torrserver_torrent_download_speed_bytes", "Download speed in bytes per second.", func(tm *torrentMetrics) float64 { return tm.status.DownloadSpeed })
+	gauge("torrserver_torrent_upload_speed_bytes", "Upload speed in bytes per second.", func(tm *torrentMetrics) float64 { return tm.status.UploadSpeed })
+	for _, tm := range list {
+		peers := []struct {
+			state string
+			count int
+		}{
+			{"total", tm.status.TotalPeers},
+			{"pending", tm.status.PendingPeers},
+			{"active", tm.status.ActivePeers},
+			{"half_open", tm.status.HalfOpenPeers},
+		}
+		for _, p := range peers {
+			w.Gauge("torrserver_torrent_peers", "Number of peers by state.", float64(p.count), append(tm.labels, "state", p.state)...)
+		}
+	}
+	gauge("torrserver_torrent_connected_seeders", "Number of connected seeders.", func(tm *torrentMetrics) float64 { return float64(tm.status.ConnectedSeeders) })
+
+	counter("torrserver_torrent_read_bytes_total", "Bytes read from peers.", func(tm *torrentMetrics) float64 { return float64(tm.status.BytesRead) })
+	counter("torrserver_torrent_read_data_bytes_total", "Data bytes read from peers.", func(tm *torrentMetrics) float64 { return float64(tm.status.BytesReadData) })
+	counter("torrserver_torrent_read_useful_data_bytes_total", "Useful data bytes read from peers.", func(tm *torrentMetrics) float64 { return float64(tm.status.BytesReadUsefulData) })
+	counter("torrserver_torrent_written_bytes_total", "Bytes written to peers.", func(tm *torrentMetrics) float64 { return float64(tm.status.BytesWritten) })
+	counter("torrserver_torrent_written_data_bytes_total", "Data bytes written to peers.", func(tm *torrentMetrics) float64 { return float64(tm.status.BytesWrittenData) })
+	counter("torrserver_torrent_chunks_read_total", "Chunks read from peers.", func(tm *torrentMetrics) float64 { return float64(tm.status.ChunksRead) })
+	counter("torrserver_torrent_chunks_read_useful_total", "Useful chunks read from peers.", func(tm *torrentMetrics) float64 { return float64(tm.status.ChunksReadUseful) })
+	counter("torrserver_torrent_chunks_read_wasted_total", "Wasted chunks read from peers.", func(tm *torrentMetrics) float64 { return float64(tm.status.ChunksReadWasted) })
+	counter("torrserver_torrent_chunks_written_total", "Chunks written to peers.", func(tm *torrentMetrics) float64 { return float64(tm.status.ChunksWritten) })
+	counter("torrserver_torrent_pieces_dirtied_good_total", "Pieces dirtied and verified good.", func(tm *torrentMetrics) float64 { return float64(tm.status.PiecesDirtiedGood) })
+	counter("torrserver_torrent_pieces_dirtied_bad_total", "Pieces dirtied and failed verification.", func(tm *torrentMetrics) float64 { return float64(tm.status.PiecesDirtiedBad) })
+
+	gauge("torrserver_torrent_download_rate_limit_bytes", "Download cap of torrent in bytes per second, 0 - no cap.", func(tm *torrentMetrics) float64 { return float64(tm.status.DownloadRateLimit * 1024) })
+	gauge("torrserver_torrent_upload_rate_limit_bytes", "Upload cap of torrent in bytes per second, 0 - no cap.", func(tm *torrentMetrics) float64 { return float64(tm.status.UploadRateLimit * 1024) })
+
+	// torrents without info have no cache yet
+	var cached []*torrentMetrics
+	for _, tm := range list {
+		if tm.cache != nil {
+			cached = append(cached, tm)
+		}
+	}
+	list = cached
+	gauge("torrserver_cache_capacity_bytes", "Memory cache capacity of torrent.", func(tm *torrentMetrics) float64 { return float64(tm.cache.Capacity) })
+	gauge("torrserver_cache_filled_bytes", "Memory cache filled by torrent.", func(tm *torrentMetrics) float64 { return float64(tm.cache.Filled) })
+	gauge("torrserver_cache_disk_filled_bytes", "Disk cache filled by torrent.", func(tm *torrentMetrics) float64 { return float64(tm.cache.DiskFilled) })
+	gauge("torrserver_cache_pieces", "Number of pieces in cache.", func(tm *torrentMetrics) float64 { return float64(len(tm.cache.Pieces)) })
+	gauge("torrserver_cache_readers", "Number of cache readers.", func(tm *torrentMetrics) float64 { return float64(len(tm.cache.Readers)) })
+	gauge("torrserver_cache_readers_active", "Number of cache readers in use.", func(tm *torrentMetrics) float64 { return float64(tm.useReaders) })
+}
@@ -1,14 +1,12 @@
 package torr
 
 import (
-	"fmt"
 	"io"
 	"sync"
 	"time"
 
 	"github.com/anacrolix/torrent"
 
-	"log"
 	"server/settings"
 	"server/torr/state"
 	utils2 "server/utils"
@@ -40,9 +38,6 @@ func (t *Torrent) Preload(index int, size int64) {
 	defer func() {
 		if t.Stat == state.TorrentPreload {
 			t.Stat = state.TorrentWorking
-			// Очистка по окончании прелоада
-			t.BitRate = ""
-			t.DurationSeconds = 0
 		}
 	}()
 
@@ -60,15 +55,17 @@ func (t *Torrent) Preload(index int, size int64) {
 		// Запуск лога в отдельном потоке
 		go func() {
 			for t.Stat == state.TorrentPreload {
-				stat := fmt.Sprint(file.Torrent().InfoHash().HexString(), " ", utils2.Format(float64(t.PreloadedBytes)), "/", utils2.Format(float64(t.PreloadSize)), " Speed:", utils2.Format(t.DownloadSpeed), " Peers:", t.Torrent.Stats().ActivePeers, "/", t.Torrent.Stats().TotalPeers, " [Seeds:", t.Torrent.Stats().ConnectedSeeders, "]")
-				log.Println("Preload:", stat)
+				stats := t.Torrent.Stats()
+				logger.Info("preload", "hash", file.Torrent().InfoHash().HexString(), "file", index,
+					"preloaded", utils2.Format(float64(t.PreloadedBytes)), "size", utils2.Format(float64(t.PreloadSize)),
+					"speed", utils2.Format(t.DownloadSpeed), "active_peers", stats.ActivePeers, "total_peers", stats.TotalPeers, "seeds", stats.ConnectedSeeders)
 				t.AddExpiredTime(timeout)
 				time.Sleep(time.Second)
 			}
 		}()
 
 		if t.Stat == state.TorrentClosed {
-			log.Println("End preload: torrent closed")
+			logger.Info("end preload: torrent closed", "hash", file.Torrent().InfoHash().HexString(), "file", index)
 			return
 		}
 
@@ -130,7 +127,7 @@ func (t *Torrent) Preload(index int, size int64) {
 		for offset+int64(len(tmp)) < readerStartEnd {
 			n, err := readerStart.Read(tmp)
 			if err != nil {
-				log.Println("Error preload:", err)
+				logger.Error("preload", "hash", file.Torrent().InfoHash().HexString(), "file", index, "err", err)
 				return
 			}
 			offset += int64(n)
@@ -141,8 +138,15 @@ func (t *Torrent) Preload(index int, size int64) {
 		}
 
 		wg.Wait()
+
+		// head and tail of file are loaded, read container index for duration and bitrate
+		if t.findFileIndex(index) != nil {
+			t.MediaIndex(index)
+		}
 	}
-	log.Println("End preload:", file.Torrent().InfoHash().HexString(), "Peers:", t.Torrent.Stats().ActivePeers, "/", t.Torrent.Stats().TotalPeers, "[ Seeds:", t.Torrent.Stats().ConnectedSeeders, "]")
+	stats := t.Torrent.Stats()
+	logger.Info("end preload", "hash", file.Torrent().InfoHash().HexString(), "file", index,
+		"active_peers", stats.ActivePeers, "total_peers", stats.TotalPeers, "seeds", stats.ConnectedSeeders)
 }
 
 func (t *Torrent) findFileIndex(index int) *torrent.File {
@@ -0,0 +1,61 @@
+package torr
+
+import (
+	"server/torr/utils"
+)
+
+// limitRate pauses data download or upload of torrent while its cap is exceeded.
+// Bytes over cap are kept as debt and paid off by next progress ticks.
+// Caps are approximate: client has only global limiters, so torrent is paused and resumed
+// by 1s progress ticks, speed bursts within tick and follows cap only on average
+func (t *Torrent) limitRate(deltaDl, deltaUp int64, deltaTime float64) {
+	if t.Torrent == nil {
+		return
+	}
+	t.dlDebt = rateDebt(t.dlDebt, deltaDl, t.DownloadRateLimit, deltaTime)
+	if paused := t.dlDebt > 0; paused != t.dlPaused {
+		t.dlPaused = paused
+		if paused {
+			t.Torrent.DisallowDataDownload()
+		} else {
+			t.Torrent.AllowDataDownload()
+		}
+	}
+	t.upDebt = rateDebt(t.upDebt, deltaUp, t.UploadRateLimit, deltaTime)
+	if paused := t.upDebt > 0; paused != t.upPaused {
+		t.upPaused = paused
+		if paused {
+			t.Torrent.DisallowDataUpload()
+		} else {
+			t.Torrent.AllowDataUpload()
+		}
+	}
+}
+
+func rateDebt(debt float64, delta int64, limit int, deltaTime float64) float64 {
+	if limit <= 0 {
+		return 0
+	}
+	return max(debt+float64(delta)-float64(limit*1024)*deltaTime, 0)
+}
+
+// SetRateLimits changes global limits of running client in kb, 0 - inf.
+// Limits are temporary and not saved, limits of settings or bandwidth profile
+// are restored on settings change, profile switch or restart
+func (bt *BTServer) SetRateLimits(download, upload int) {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+	if bt.config == nil {
+		return
+	}
+	utils.SetLimit(bt.config.DownloadRateLimiter, download*1024)
+	utils.SetLimit(bt.config.UploadRateLimiter, upload*1024)
+	bt.downloadLimit, bt.uploadLimit = download, upload
+}
+
+// RateLimits returns current global limits in kb, 0 - inf
+func (bt *BTServer) RateLimits() (download, upload int) {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+	return bt.downloadLimit, bt.uploadLimit
+}
@@ -0,0 +1,72 @@
+package torr
+
+import (
+	"time"
+
+	"server/settings"
+	"server/torr/utils"
+)
+
+const scheduleInterval = 30 * time.Second
+
+// applyLimits sets rate and connections limits of bandwidth profile active by schedule,
+// limits of settings are used out of schedule. Must be called with bt.mu locked
+func (bt *BTServer) applyLimits() {
+	download := settings.BTsets.DownloadRateLimit
+	upload := settings.BTsets.UploadRateLimit
+	conns := settings.BTsets.ConnectionsLimit
+	name := ""
+	if p := settings.BTsets.BandwidthProfileAt(time.Now()); p != nil {
+		name = p.Name
+		download, upload = p.DownloadRateLimit, p.UploadRateLimit
+		if p.ConnectionsLimit > 0 {
+			conns = p.ConnectionsLimit
+		}
+	}
+
+	utils.SetLimit(bt.config.DownloadRateLimiter, download*1024)
+	utils.SetLimit(bt.config.UploadRateLimiter, upload*1024)
+	bt.downloadLimit, bt.uploadLimit = download, upload
+	bt.config.EstablishedConnsPerTorrent = conns
+	for _, t := range bt.torrents {
+		if t.Torrent != nil {
+			t.Torrent.SetMaxEstablishedConns(conns)
+		}
+	}
+
+	if name != bt.profile {
+		if name == "" {
+			logger.Info("bandwidth profile: settings limits")
+		} else {
+			logger.Info("bandwidth profile", "name", name)
+		}
+	}
+	bt.profile = name
+}
+
+// watchSchedule switches bandwidth profiles, limits are applied only when profile changes
+// to keep limits changed by api until next switch
+func (bt *BTServer) watchSchedule() {
+	ticker := time.NewTicker(scheduleInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		bt.mu.Lock()
+		if bt.client != nil {
+			name := ""
+			if p := settings.BTsets.BandwidthProfileAt(time.Now()); p != nil {
+				name = p.Name
+			}
+			if name != bt.profile {
+				bt.applyLimits()
+			}
+		}
+		bt.mu.Unlock()
+	}
+}
+
+// ActiveProfile returns name of active bandwidth profile, empty if settings limits are used
+func (bt *BTServer) ActiveProfile() string {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+	return bt.profile
+}
@@ -0,0 +1,64 @@
+package state
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Release is info parsed from release name of torrent or file
+type Release struct {
+	Title      string `json:"title,omitempty"`
+	Year       int    `json:"year,omitempty"`
+	Season     int    `json:"season,omitempty"`
+	Episode    int    `json:"episode,omitempty"`
+	Resolution string `json:"resolution,omitempty"` // 2160p, 1080p, 720p ...
+	Codec      string `json:"codec,omitempty"`      // x264, h264, hevc ...
+	Group      string `json:"group,omitempty"`
+	Edited     bool   `json:"edited,omitempty"` // set by user, edited info is not parsed again
+}
+
+// Fill sets empty fields of r from o, episode is not taken
+func (r *Release) Fill(o *Release) {
+	if o == nil {
+		return
+	}
+	if r.Title == "" {
+		r.Title = o.Title
+	}
+	if r.Year == 0 {
+		r.Year = o.Year
+	}
+	if r.Season == 0 {
+		r.Season = o.Season
+	}
+	if r.Resolution == "" {
+		r.Resolution = o.Resolution
+	}
+	if r.Codec == "" {
+		r.Codec = o.Codec
+	}
+	if r.Group == "" {
+		r.Group = o.Group
+	}
+}
+
+// String returns display title: Title (Year) S01E02 [1080p]
+func (r *Release) String() string {
+	s := r.Title
+	if r.Year > 0 {
+		s += " (" + strconv.Itoa(r.Year) + ")"
+	}
+	switch {
+	case r.Season > 0 && r.Episode > 0:
+		s += fmt.Sprintf(" S%02dE%02d", r.Season, r.Episode)
+	case r.Season > 0:
+		s += fmt.Sprintf(" S%02d", r.Season)
+	case r.Episode > 0:
+		s += fmt.Sprintf(" E%02d", r.Episode)
+	}
+	if r.Resolution != "" {
+		s += " [" + r.Resolution + "]"
+	}
+	return strings.TrimSpace(s)
+}
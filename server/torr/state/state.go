@@ -64,6 +64,10 @@ type TorrentStatus struct {
 	PiecesDirtiedBad    int64       `json:"pieces_dirtied_bad,omitempty"`
 	DurationSeconds     float64     `json:"duration_seconds,omitempty"`
 	BitRate             string      `json:"bit_rate,omitempty"`
+	DownloadRateLimit   int         `json:"download_rate_limit,omitempty"`
+	UploadRateLimit     int         `json:"upload_rate_limit,omitempty"`
+
+	Release *Release `json:"release,omitempty"`
 
 	FileStats []*TorrentFileStat `json:"file_stats,omitempty"`
 }
@@ -72,4 +76,6 @@ type TorrentFileStat struct {
 	Id     int    `json:"id,omitempty"`
 	Path   string `json:"path,omitempty"`
 	Length int64  `json:"length,omitempty"`
+
+	Release *Release `json:"release,omitempty"` // saved in torrent data for media files
 }
@@ -8,6 +8,7 @@ type CacheState struct {
 	Hash         string
 	Capacity     int64
 	Filled       int64
+	DiskFilled   int64
 	PiecesLength int64
 	PiecesCount  int
 	Torrent      *state.TorrentStatus
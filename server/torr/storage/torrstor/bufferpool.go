@@ -0,0 +1,58 @@
+package torrstor
+
+import (
+	"math/bits"
+	"sync"
+)
+
+// BufferPool recycles piece buffers by power of two size classes
+type BufferPool struct {
+	pools map[int]*sync.Pool
+	mu    sync.Mutex
+}
+
+func NewBufferPool() *BufferPool {
+	return &BufferPool{pools: make(map[int]*sync.Pool)}
+}
+
+func sizeClass(size int64) int {
+	if size <= 1 {
+		return 0
+	}
+	return bits.Len64(uint64(size - 1))
+}
+
+func (b *BufferPool) pool(class int) *sync.Pool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	p, ok := b.pools[class]
+	if !ok {
+		size := 1 << class
+		p = &sync.Pool{New: func() any {
+			buf := make([]byte, size)
+			return &buf
+		}}
+		b.pools[class] = p
+	}
+	return p
+}
+
+func (b *BufferPool) Get(size int64) []byte {
+	buf := *b.pool(sizeClass(size)).Get().(*[]byte)
+	buf = buf[:size]
+	clear(buf)
+	return buf
+}
+
+func (b *BufferPool) Put(buf []byte) {
+	if cap(buf) == 0 {
+		return
+	}
+	class := sizeClass(int64(cap(buf)))
+	if 1<<class != cap(buf) {
+		// not from pool
+		return
+	}
+	buf = buf[:cap(buf)]
+	b.pool(class).Put(&buf)
+}
@@ -0,0 +1,83 @@
+package torrstor
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+const benchPieceLength = 2 << 20
+
+func benchCache() *Cache {
+	stor := &Storage{caches: make(map[metainfo.Hash]*Cache), pool: NewBufferPool()}
+	stor.capacity.Store(benchPieceLength * 16)
+	c := NewCache(benchPieceLength*16, stor)
+	c.pieceLength = benchPieceLength
+	c.pieceCount = 1
+	c.length = benchPieceLength
+	return c
+}
+
+// benchmarkMemPiece writes piece by 16 KB blocks as peers do, reads it by 64 KB as stream does and evicts it,
+// unpooled eviction drops buffer, so every piece allocates new one like before pool
+func benchmarkMemPiece(b *testing.B, pooled bool) {
+	c := benchCache()
+	p := &Piece{Id: 0, cache: c}
+	p.mPiece = NewMemPiece(p)
+	block := make([]byte, 16<<10)
+	buf := make([]byte, 64<<10)
+	reads := make([]time.Duration, 0, b.N*benchPieceLength/len(buf))
+
+	b.ReportAllocs()
+	b.SetBytes(benchPieceLength)
+	b.ResetTimer()
+	for range b.N {
+		for off := int64(0); off < benchPieceLength; off += int64(len(block)) {
+			p.mPiece.WriteAt(block, off)
+		}
+		for off := int64(0); off < benchPieceLength; off += int64(len(buf)) {
+			start := time.Now()
+			p.mPiece.ReadAt(buf, off)
+			reads = append(reads, time.Since(start))
+		}
+		if pooled {
+			p.mPiece.Release()
+		} else {
+			p.mPiece.mu.Lock()
+			p.mPiece.buffer = nil
+			p.Size = 0
+			p.mPiece.mu.Unlock()
+		}
+	}
+	b.StopTimer()
+
+	slices.Sort(reads)
+	if len(reads) > 0 {
+		b.ReportMetric(float64(reads[len(reads)*99/100].Nanoseconds()), "p99-read-ns")
+	}
+}
+
+func BenchmarkMemPiecePooled(b *testing.B) {
+	benchmarkMemPiece(b, true)
+}
+
+func BenchmarkMemPieceUnpooled(b *testing.B) {
+	benchmarkMemPiece(b, false)
+}
+
+func TestBufferPoolSizeClass(t *testing.T) {
+	pool := NewBufferPool()
+	buf := pool.Get(1000)
+	if len(buf) != 1000 || cap(buf) != 1024 {
+		t.Fatalf("len %d cap %d, want 1000 1024", len(buf), cap(buf))
+	}
+	buf[0] = 1
+	pool.Put(buf)
+	// foreign buffer is not pooled
+	pool.Put(make([]byte, 1000))
+	if buf = pool.Get(1000); buf[0] != 0 {
+		t.Fatal("pooled buffer is not cleared")
+	}
+}
@@ -3,14 +3,13 @@ package torrstor
 import (
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/anacrolix/torrent"
 
-	"log"
 	"server/settings"
 	"server/torr/storage/state"
-	"server/torr/utils"
 
 	"github.com/anacrolix/torrent/metainfo"
 	"github.com/anacrolix/torrent/storage"
@@ -20,18 +19,21 @@ type Cache struct {
 	storage.TorrentImpl
 	storage *Storage
 
-	capacity int64
-	filled   int64
+	capacity atomic.Int64 // changed by budget rebalance while pieces are cleaned
+	filled   atomic.Int64 // summed by storage clean of other caches
 	hash     metainfo.Hash
 
 	pieceLength int64
 	pieceCount  int
+	length      int64
 
 	pieces map[int]*Piece
 
 	readers   map[*Reader]struct{}
 	muReaders sync.Mutex
 
+	accessed atomic.Int64 // unix time of last read, set by readers
+
 	isRemove bool
 	isClosed bool
 	muRemove sync.Mutex
@@ -40,29 +42,44 @@ type Cache struct {
 
 func NewCache(capacity int64, storage *Storage) *Cache {
 	ret := &Cache{
-		capacity: capacity,
-		filled:   0,
-		pieces:   make(map[int]*Piece),
-		storage:  storage,
-		readers:  make(map[*Reader]struct{}),
+		pieces:  make(map[int]*Piece),
+		storage: storage,
+		readers: make(map[*Reader]struct{}),
 	}
+	ret.capacity.Store(capacity)
 
 	return ret
 }
 
 func (c *Cache) Init(info *metainfo.Info, hash metainfo.Hash) {
-	log.Println("Create cache for:", info.Name, hash.HexString())
-	if c.capacity == 0 {
-		c.capacity = info.PieceLength * 4
+	logger.Info("create cache", "hash", hash.HexString(), "name", info.Name)
+	if c.capacity.Load() == 0 {
+		c.capacity.Store(minCapacity(info.PieceLength))
 	}
 
 	c.pieceLength = info.PieceLength
 	c.pieceCount = info.NumPieces()
+	c.length = info.TotalLength()
 	c.hash = hash
 
 	for i := range c.pieceCount {
 		c.pieces[i] = NewPiece(i, c)
+		if p := c.pieces[i].dPiece; p != nil && p.Stored() {
+			// piece saved by previous open is complete after torrent checks its hash
+			if p.Size() == c.pieceSize(i) {
+				c.pieces[i].unchecked = true
+			} else {
+				p.Release()
+			}
+		}
+	}
+}
+
+func (c *Cache) pieceSize(id int) int64 {
+	if id == c.pieceCount-1 {
+		return c.length - int64(id)*c.pieceLength
 	}
+	return c.pieceLength
 }
 
 func (c *Cache) SetTorrent(torr *torrent.Torrent) {
@@ -77,17 +94,27 @@ func (c *Cache) Piece(m metainfo.Piece) storage.PieceImpl {
 }
 
 func (c *Cache) Close() error {
-	log.Println("Close cache for:", c.hash)
+	logger.Info("close cache", "hash", c.hash.HexString())
 	c.isClosed = true
 
 	delete(c.storage.caches, c.hash)
 
+	if c.storage.disk != nil {
+		if settings.BTsets.RemoveCacheOnDrop {
+			c.storage.disk.removeHash(c.hash)
+		} else {
+			c.storage.disk.detachHash(c.hash)
+		}
+	}
+
 	c.muReaders.Lock()
+	for _, p := range c.pieces {
+		p.mPiece.Release()
+	}
 	c.readers = nil
 	c.pieces = nil
 	c.muReaders.Unlock()
 
-	utils.FreeOSMemGC()
 	return nil
 }
 
@@ -99,7 +126,7 @@ func (c *Cache) removePiece(piece *Piece) {
 
 func (c *Cache) AdjustRA(readahead int64) {
 	if settings.BTsets.CacheSize == 0 {
-		c.capacity = readahead * 3
+		c.capacity.Store(readahead * 3)
 	}
 	if c.Readers() > 0 {
 		c.muReaders.Lock()
@@ -147,12 +174,13 @@ func (c *Cache) GetState() *state.CacheState {
 		c.muReaders.Unlock()
 	}
 
-	c.filled = fill
-	cState.Capacity = c.capacity
+	c.filled.Store(fill)
+	cState.Capacity = c.capacity.Load()
 	cState.PiecesLength = c.pieceLength
 	cState.PiecesCount = c.pieceCount
 	cState.Hash = c.hash.HexString()
 	cState.Filled = fill
+	cState.DiskFilled = c.GetDiskFilled()
 	cState.Pieces = piecesState
 	cState.Readers = readersState
 	return cState
@@ -171,24 +199,34 @@ func (c *Cache) cleanPieces() {
 	defer func() { c.isRemove = false }()
 	c.muRemove.Unlock()
 
+	c.storage.updateCapacity()
+
 	remPieces := c.getRemPieces()
-	if c.filled > c.capacity {
-		rems := (c.filled-c.capacity)/c.pieceLength + 1
+	if capacity, filled := c.capacity.Load(), c.filled.Load(); filled > capacity {
+		rems := (filled-capacity)/c.pieceLength + 1
 		for _, p := range remPieces {
 			c.removePiece(p)
 			rems--
 			if rems <= 0 {
-				utils.FreeOSMemGC()
-				return
+				break
 			}
 		}
 	}
+
+	c.storage.cleanPieces()
 }
 
 func (c *Cache) getRemPieces() []*Piece {
-	piecesRemove := make([]*Piece, 0)
-	fill := int64(0)
+	ranges := c.getReadersRanges()
+	piecesRemove := c.getOutRangesPieces(ranges)
+
+	c.clearPriority()
+	c.setLoadPriority(ranges)
+
+	return piecesRemove
+}
 
+func (c *Cache) getReadersRanges() []Range {
 	ranges := make([]Range, 0)
 	c.muReaders.Lock()
 	for r := range c.readers {
@@ -198,7 +236,13 @@ func (c *Cache) getRemPieces() []*Piece {
 		}
 	}
 	c.muReaders.Unlock()
-	ranges = mergeRange(ranges)
+	return mergeRange(ranges)
+}
+
+// getOutRangesPieces returns filled pieces out of readers ranges, least recently accessed first
+func (c *Cache) getOutRangesPieces(ranges []Range) []*Piece {
+	piecesRemove := make([]*Piece, 0)
+	fill := int64(0)
 
 	for id, p := range c.pieces {
 		if p.Size > 0 {
@@ -218,17 +262,25 @@ func (c *Cache) getRemPieces() []*Piece {
 		}
 	}
 
-	c.clearPriority()
-	c.setLoadPriority(ranges)
-
 	sort.Slice(piecesRemove, func(i, j int) bool {
 		return piecesRemove[i].Accessed < piecesRemove[j].Accessed
 	})
 
-	c.filled = fill
+	c.filled.Store(fill)
 	return piecesRemove
 }
 
+// weight of cache in shared memory budget
+func (c *Cache) weight() int64 {
+	if readers := int64(c.GetUseReaders()); readers > 0 {
+		return readers * 4
+	}
+	if time.Now().Unix() < c.accessed.Load()+60 {
+		return 2
+	}
+	return 0
+}
+
 func (c *Cache) setLoadPriority(ranges []Range) {
 	c.muReaders.Lock()
 	for r := range c.readers {
@@ -352,9 +404,27 @@ func (c *Cache) clearPriority() {
 	}
 }
 
+func (c *Cache) GetDiskFilled() int64 {
+	if c == nil || c.storage.disk == nil {
+		return 0
+	}
+	var fill int64
+	for _, p := range c.pieces {
+		if p.dPiece != nil {
+			fill += p.dPiece.Size()
+		}
+	}
+	return fill
+}
+
 func (c *Cache) GetCapacity() int64 {
 	if c == nil {
 		return 0
 	}
-	return c.capacity
+	return c.capacity.Load()
+}
+
+// minCapacity is capacity kept by every cache whatever its share of budget
+func minCapacity(pieceLength int64) int64 {
+	return pieceLength * 4
 }
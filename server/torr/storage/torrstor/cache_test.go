@@ -0,0 +1,52 @@
+package torrstor
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/anacrolix/torrent/storage"
+)
+
+// TestCacheInitDiskPieces checks pieces saved by previous open are complete only after hash check of torrent
+func TestCacheInitDiskPieces(t *testing.T) {
+	dir := t.TempDir()
+	hash := metainfo.Hash{1}
+	data := bytes.Repeat([]byte{7}, 16)
+	os.MkdirAll(filepath.Join(dir, hash.HexString()), 0o777)
+	os.WriteFile(filepath.Join(dir, hash.HexString(), "0"), data, 0o666)
+	os.WriteFile(filepath.Join(dir, hash.HexString(), "1"), data, 0o666)
+	os.WriteFile(filepath.Join(dir, hash.HexString(), "2"), data[:5], 0o666) // truncated
+
+	stor := &Storage{caches: make(map[metainfo.Hash]*Cache), pool: NewBufferPool(), disk: NewDiskStore(dir, 1<<20)}
+	c := NewCache(1<<20, stor)
+	c.Init(&metainfo.Info{Name: "a", PieceLength: 16, Length: 48, Pieces: make([]byte, 3*20)}, hash)
+
+	for i, want := range []storage.Completion{{Complete: false, Ok: false}, {Complete: false, Ok: false}, {Complete: false, Ok: true}} {
+		if got := c.pieces[i].Completion(); got != want {
+			t.Errorf("piece %d: completion %+v, want %+v", i, got, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, hash.HexString(), "2")); !os.IsNotExist(err) {
+		t.Error("truncated piece is kept")
+	}
+
+	// hash check reads piece from disk
+	buf := make([]byte, 16)
+	if n, err := c.pieces[0].ReadAt(buf, 0); n != 16 || err != nil || !bytes.Equal(buf, data) {
+		t.Fatalf("read of saved piece %d %v", n, err)
+	}
+	c.pieces[0].MarkComplete()
+	c.pieces[1].MarkNotComplete()
+	if got := c.pieces[0].Completion(); got != (storage.Completion{Complete: true, Ok: true}) {
+		t.Errorf("checked piece: completion %+v", got)
+	}
+	if got := c.pieces[1].Completion(); got != (storage.Completion{Complete: false, Ok: true}) {
+		t.Errorf("failed piece: completion %+v", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, hash.HexString(), "1")); !os.IsNotExist(err) {
+		t.Error("piece failed hash check is kept on disk")
+	}
+}
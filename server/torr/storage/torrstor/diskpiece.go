@@ -0,0 +1,88 @@
+package torrstor
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type DiskPiece struct {
+	piece *Piece
+
+	name string
+	size int64
+	mu   sync.RWMutex
+}
+
+func NewDiskPiece(p *Piece) *DiskPiece {
+	name := filepath.Join(p.cache.storage.disk.hashPath(p.cache.hash), strconv.Itoa(p.Id))
+	d := &DiskPiece{piece: p, name: name}
+	// piece saved earlier, only complete pieces are written to disk
+	d.size, p.Accessed = p.cache.storage.disk.attach(d)
+	return d
+}
+
+func (d *DiskPiece) Store(b []byte) error {
+	d.mu.Lock()
+	err := os.MkdirAll(filepath.Dir(d.name), 0o777)
+	if err == nil {
+		err = os.WriteFile(d.name, b, 0o666)
+	}
+	if err != nil {
+		d.mu.Unlock()
+		os.Remove(d.name)
+		return err
+	}
+	d.size = int64(len(b))
+	d.mu.Unlock()
+
+	d.piece.cache.storage.disk.add(d, int64(len(b)), time.Now().Unix())
+	return nil
+}
+
+func (d *DiskPiece) ReadAt(b []byte, off int64) (n int, err error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.size == 0 || off >= d.size {
+		return 0, io.EOF
+	}
+	ff, err := os.Open(d.name)
+	if err != nil {
+		return 0, err
+	}
+	defer ff.Close()
+
+	n, err = ff.ReadAt(b, off)
+	d.piece.cache.storage.disk.touch(d, time.Now().Unix())
+	if n > 0 && err == io.EOF {
+		err = nil
+	}
+	return
+}
+
+func (d *DiskPiece) Stored() bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.size > 0
+}
+
+func (d *DiskPiece) Size() int64 {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.size
+}
+
+func (d *DiskPiece) Release() {
+	d.mu.Lock()
+	if d.size > 0 {
+		os.Remove(d.name)
+		d.size = 0
+	}
+	d.mu.Unlock()
+
+	d.piece.cache.storage.disk.remove(d)
+}
@@ -0,0 +1,219 @@
+package torrstor
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// diskEntry is a piece file on disk, piece is nil while torrent is not opened
+type diskEntry struct {
+	hash     metainfo.Hash
+	size     int64
+	accessed int64
+	piece    *DiskPiece
+}
+
+type DiskStore struct {
+	path     string
+	capacity int64
+	filled   int64
+
+	entries map[string]*diskEntry
+	mu      sync.Mutex
+
+	isClean bool
+}
+
+func NewDiskStore(path string, capacity int64) *DiskStore {
+	logger.Info("use disk piece storage", "path", path)
+	s := &DiskStore{
+		path:     path,
+		capacity: capacity,
+		entries:  make(map[string]*diskEntry),
+	}
+	s.load()
+	go s.cleanPieces()
+	return s
+}
+
+// load indexes pieces saved by previous runs
+func (s *DiskStore) load() {
+	dirs, err := os.ReadDir(s.path)
+	if err != nil {
+		return
+	}
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		var hash metainfo.Hash
+		if err := hash.FromHexString(dir.Name()); err != nil {
+			continue
+		}
+		files, err := os.ReadDir(filepath.Join(s.path, dir.Name()))
+		if err != nil {
+			continue
+		}
+		for _, file := range files {
+			if _, err := strconv.Atoi(file.Name()); err != nil {
+				continue
+			}
+			fi, err := file.Info()
+			if err != nil || fi.Size() == 0 {
+				continue
+			}
+			s.entries[filepath.Join(s.path, dir.Name(), file.Name())] = &diskEntry{
+				hash:     hash,
+				size:     fi.Size(),
+				accessed: fi.ModTime().Unix(),
+			}
+			s.filled += fi.Size()
+		}
+	}
+	logger.Info("disk pieces loaded", "count", len(s.entries), "size", s.filled)
+}
+
+func (s *DiskStore) hashPath(hash metainfo.Hash) string {
+	return filepath.Join(s.path, hash.HexString())
+}
+
+// attach binds piece to the file saved earlier and returns its size and access time
+func (s *DiskStore) attach(d *DiskPiece) (int64, int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[d.name]; ok {
+		e.piece = d
+		return e.size, e.accessed
+	}
+	return 0, 0
+}
+
+func (s *DiskStore) add(d *DiskPiece, size, accessed int64) {
+	s.mu.Lock()
+	e, ok := s.entries[d.name]
+	if !ok {
+		e = &diskEntry{hash: d.piece.cache.hash}
+		s.entries[d.name] = e
+	}
+	s.filled += size - e.size
+	e.size = size
+	e.accessed = accessed
+	e.piece = d
+	s.mu.Unlock()
+	go s.cleanPieces()
+}
+
+func (s *DiskStore) touch(d *DiskPiece, accessed int64) {
+	s.mu.Lock()
+	if e, ok := s.entries[d.name]; ok {
+		e.accessed = accessed
+	}
+	s.mu.Unlock()
+}
+
+func (s *DiskStore) remove(d *DiskPiece) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[d.name]; ok {
+		s.filled -= e.size
+		delete(s.entries, d.name)
+	}
+}
+
+// detachHash keeps pieces of closed torrent on disk for next open
+func (s *DiskStore) detachHash(hash metainfo.Hash) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.entries {
+		if e.hash == hash {
+			e.piece = nil
+		}
+	}
+}
+
+// removeHash drops all disk pieces of torrent
+func (s *DiskStore) removeHash(hash metainfo.Hash) {
+	s.mu.Lock()
+	for name, e := range s.entries {
+		if e.hash == hash {
+			s.filled -= e.size
+			delete(s.entries, name)
+		}
+	}
+	s.mu.Unlock()
+	if err := os.RemoveAll(s.hashPath(hash)); err != nil {
+		logger.Error("remove disk cache", "hash", hash.HexString(), "err", err)
+	}
+}
+
+func (s *DiskStore) GetFilled() int64 {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.filled
+}
+
+func (s *DiskStore) SetCapacity(capacity int64) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.capacity = capacity
+	s.mu.Unlock()
+	go s.cleanPieces()
+}
+
+func (s *DiskStore) cleanPieces() {
+	s.mu.Lock()
+	if s.isClean || s.filled <= s.capacity {
+		s.mu.Unlock()
+		return
+	}
+	s.isClean = true
+	defer func() {
+		s.mu.Lock()
+		s.isClean = false
+		s.mu.Unlock()
+	}()
+
+	type remEntry struct {
+		name     string
+		size     int64
+		accessed int64
+		piece    *DiskPiece
+	}
+	remEntries := make([]remEntry, 0, len(s.entries))
+	for name, e := range s.entries {
+		remEntries = append(remEntries, remEntry{name, e.size, e.accessed, e.piece})
+	}
+	rems := s.filled - s.capacity
+	sort.Slice(remEntries, func(i, j int) bool {
+		return remEntries[i].accessed < remEntries[j].accessed
+	})
+	s.mu.Unlock()
+
+	for _, e := range remEntries {
+		if e.piece != nil {
+			e.piece.piece.releaseDisk()
+		} else {
+			s.mu.Lock()
+			if de, ok := s.entries[e.name]; ok && de.piece == nil {
+				s.filled -= de.size
+				delete(s.entries, e.name)
+				os.Remove(e.name)
+			}
+			s.mu.Unlock()
+		}
+		rems -= e.size
+		if rems <= 0 {
+			return
+		}
+	}
+}
@@ -23,7 +23,7 @@ func (p *MemPiece) WriteAt(b []byte, off int64) (n int, err error) {
 
 	if p.buffer == nil {
 		go p.piece.cache.cleanPieces()
-		p.buffer = make([]byte, p.piece.cache.pieceLength)
+		p.buffer = p.piece.cache.storage.pool.Get(p.piece.cache.pieceLength)
 	}
 	n = copy(p.buffer[off:], b[:])
 	p.piece.Size += int64(n)
@@ -56,10 +56,45 @@ func (p *MemPiece) ReadAt(b []byte, off int64) (n int, err error) {
 	return n, nil
 }
 
+func (p *MemPiece) Loaded() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.buffer != nil
+}
+
+// Flush writes complete piece to disk and keeps it in memory
+func (p *MemPiece) Flush(d *DiskPiece) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	length := p.piece.cache.pieceSize(p.piece.Id)
+	if int64(len(p.buffer)) < length {
+		return false
+	}
+	if err := d.Store(p.buffer[:length]); err != nil {
+		logger.Error("store piece on disk", "hash", p.piece.cache.hash.HexString(), "piece", p.piece.Id, "err", err)
+		return false
+	}
+	return true
+}
+
+// Spill moves complete piece from memory to disk
+func (p *MemPiece) Spill(d *DiskPiece) bool {
+	if !d.Stored() && !p.Flush(d) {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.piece.cache.storage.pool.Put(p.buffer)
+	p.buffer = nil
+	p.piece.Size = 0
+	return true
+}
+
 func (p *MemPiece) Release() {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	if p.buffer != nil {
+		p.piece.cache.storage.pool.Put(p.buffer)
 		p.buffer = nil
 	}
 	p.piece.Size = 0
@@ -1,6 +1,8 @@
 package torrstor
 
 import (
+	"time"
+
 	"github.com/anacrolix/torrent"
 	"github.com/anacrolix/torrent/storage"
 )
@@ -14,7 +16,10 @@ type Piece struct {
 	Complete bool  `json:"complete"`
 	Accessed int64 `json:"accessed"`
 
+	unchecked bool // read from disk on open, torrent checks hash of piece with unknown completion
+
 	mPiece *MemPiece  `json:"-"`
+	dPiece *DiskPiece `json:"-"`
 
 	cache *Cache `json:"-"`
 }
@@ -26,6 +31,9 @@ func NewPiece(id int, cache *Cache) *Piece {
 	}
 
 	p.mPiece = NewMemPiece(p)
+	if cache.storage.disk != nil {
+		p.dPiece = NewDiskPiece(p)
+	}
 	return p
 }
 
@@ -34,30 +42,66 @@ func (p *Piece) WriteAt(b []byte, off int64) (n int, err error) {
 }
 
 func (p *Piece) ReadAt(b []byte, off int64) (n int, err error) {
+	if p.dPiece != nil && !p.mPiece.Loaded() && p.dPiece.Stored() {
+		p.Accessed = time.Now().Unix()
+		return p.dPiece.ReadAt(b, off)
+	}
 	return p.mPiece.ReadAt(b, off)
 }
 
 func (p *Piece) MarkComplete() error {
 	p.Complete = true
+	p.unchecked = false
+	if p.dPiece != nil && !p.dPiece.Stored() {
+		// keep hash-verified piece for next open
+		p.mPiece.Flush(p.dPiece)
+	}
 	return nil
 }
 
 func (p *Piece) MarkNotComplete() error {
 	p.Complete = false
+	p.unchecked = false
+	if p.dPiece != nil {
+		p.dPiece.Release()
+	}
 	return nil
 }
 
 func (p *Piece) Completion() storage.Completion {
 	return storage.Completion{
 		Complete: p.Complete,
-		Ok:       true,
+		Ok:       !p.unchecked,
 	}
 }
 
 func (p *Piece) Release() {
+	// complete piece pushed out of memory drops to disk and stays complete
+	if p.dPiece != nil && p.Complete && p.mPiece.Spill(p.dPiece) {
+		if !p.cache.isClosed {
+			p.cache.torrent.Piece(p.Id).SetPriority(torrent.PiecePriorityNone)
+		}
+		return
+	}
 	p.mPiece.Release()
+	if p.dPiece != nil {
+		p.dPiece.Release()
+		p.unchecked = false
+	}
 	if !p.cache.isClosed {
 		p.cache.torrent.Piece(p.Id).SetPriority(torrent.PiecePriorityNone)
 		p.cache.torrent.Piece(p.Id).UpdateCompletion()
 	}
 }
+
+func (p *Piece) releaseDisk() {
+	p.dPiece.Release()
+	p.unchecked = false
+	if p.mPiece.Loaded() {
+		return
+	}
+	p.Complete = false
+	if !p.cache.isClosed {
+		p.cache.torrent.Piece(p.Id).UpdateCompletion()
+	}
+}
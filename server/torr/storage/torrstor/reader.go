@@ -7,7 +7,6 @@ import (
 
 	"github.com/anacrolix/torrent"
 
-	"log"
 	"server/settings"
 )
 
@@ -57,6 +56,7 @@ func (r *Reader) Seek(offset int64, whence int) (n int64, err error) {
 	n, err = r.Reader.Seek(offset, whence)
 	r.offset = n
 	r.lastAccess = time.Now().Unix()
+	r.cache.accessed.Store(r.lastAccess)
 	return
 }
 
@@ -89,15 +89,16 @@ func (r *Reader) Read(p []byte) (n int, err error) {
 
 		r.offset += int64(n)
 		r.lastAccess = time.Now().Unix()
+		r.cache.accessed.Store(r.lastAccess)
 	} else {
-		log.Println("Torrent closed and readed")
+		logger.Debug("read of closed torrent", "hash", r.cache.hash.HexString())
 	}
 	return
 }
 
 func (r *Reader) SetReadahead(length int64) {
-	if r.cache != nil && length > r.cache.capacity {
-		length = r.cache.capacity
+	if r.cache != nil && length > r.cache.capacity.Load() {
+		length = r.cache.capacity.Load()
 	}
 	if r.isUse {
 		r.Reader.SetReadahead(length)
@@ -147,8 +148,9 @@ func (r *Reader) getOffsetRange() (int64, int64) {
 		readers = 1
 	}
 
-	beginOffset := r.offset - (r.cache.capacity/readers)*(100-prc)/100
-	endOffset := r.offset + (r.cache.capacity/readers)*prc/100
+	capacity := r.cache.capacity.Load()
+	beginOffset := r.offset - (capacity/readers)*(100-prc)/100
+	endOffset := r.offset + (capacity/readers)*prc/100
 
 	if beginOffset < 0 {
 		beginOffset = 0
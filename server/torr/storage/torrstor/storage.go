@@ -2,38 +2,59 @@ package torrstor
 
 import (
 	"context"
+	"path/filepath"
+	"sort"
 	"sync"
+	"sync/atomic"
 
+	"server/logs"
+	"server/settings"
 	"server/torr/storage"
 
 	"github.com/anacrolix/torrent/metainfo"
 	ts "github.com/anacrolix/torrent/storage"
 )
 
+var logger = logs.For("torrstor")
+
 type Storage struct {
 	storage.Storage
 
 	caches   map[metainfo.Hash]*Cache
-	capacity int64
+	capacity atomic.Int64
 	mu       sync.Mutex
+
+	isClean atomic.Bool // one clean at time, others are skipped
+
+	pool *BufferPool
+	disk *DiskStore
 }
 
 func NewStorage(capacity int64) *Storage {
 	stor := new(Storage)
-	stor.capacity = capacity
+	stor.capacity.Store(capacity)
 	stor.caches = make(map[metainfo.Hash]*Cache)
+	stor.pool = NewBufferPool()
+	if settings.BTsets.UseDisk {
+		path := settings.BTsets.TorrentsSavePath
+		if path == "" {
+			path = filepath.Join(settings.Path, "cache")
+		}
+		stor.disk = NewDiskStore(path, settings.BTsets.DiskCacheSize)
+	}
 	return stor
 }
 
 func (s *Storage) OpenTorrent(contx context.Context, info *metainfo.Info, infoHash metainfo.Hash) (ts.TorrentImpl, error) {
 	capFunc := func() (int64, bool) { //	NE
-	 	return s.capacity, true //	NE
+	 	return s.capacity.Load(), true //	NE
 	} //	NE
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	ch := NewCache(s.capacity, s)
+	ch := NewCache(s.capacity.Load(), s)
 	ch.Init(info, infoHash)
 	s.caches[infoHash] = ch
+	go s.updateCapacity()
 	// return ch, nil //	OE
 	return ts.TorrentImpl{ //	NE
 	 	Piece:    ch.Piece, //	NE
@@ -71,3 +92,93 @@ func (s *Storage) GetCache(hash metainfo.Hash) *Cache {
 	}
 	return nil
 }
+
+func (s *Storage) getCaches() []*Cache {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	caches := make([]*Cache, 0, len(s.caches))
+	for _, ch := range s.caches {
+		caches = append(caches, ch)
+	}
+	return caches
+}
+
+// SetCapacity changes memory budget of running storage
+func (s *Storage) SetCapacity(capacity int64) {
+	s.capacity.Store(capacity)
+	s.updateCapacity()
+	s.cleanPieces()
+	s.disk.SetCapacity(settings.BTsets.DiskCacheSize)
+}
+
+// updateCapacity shares memory budget between caches by active readers and recent access,
+// idle caches keep only few pieces
+func (s *Storage) updateCapacity() {
+	caches := s.getCaches()
+	if len(caches) == 0 {
+		return
+	}
+
+	weights := make([]int64, len(caches))
+	var sum int64
+	for i, ch := range caches {
+		weights[i] = ch.weight()
+		sum += weights[i]
+	}
+	if sum == 0 {
+		for i := range weights {
+			weights[i] = 1
+		}
+		sum = int64(len(weights))
+	}
+
+	// minimums are enforced, so only the rest of budget is shared by weights
+	budget := s.capacity.Load()
+	for _, ch := range caches {
+		budget -= minCapacity(ch.pieceLength)
+	}
+	budget = max(budget, 0)
+	for i, ch := range caches {
+		ch.capacity.Store(minCapacity(ch.pieceLength) + budget*weights[i]/sum)
+	}
+}
+
+// cleanPieces removes least recently accessed pieces of all caches out of readers ranges
+// while summary filled is over memory budget
+func (s *Storage) cleanPieces() {
+	if !s.isClean.CompareAndSwap(false, true) {
+		return
+	}
+	defer s.isClean.Store(false)
+
+	caches := s.getCaches()
+	var filled int64
+	for _, ch := range caches {
+		filled += ch.filled.Load()
+	}
+	capacity := s.capacity.Load()
+	if filled <= capacity {
+		return
+	}
+
+	remPieces := make([]*Piece, 0)
+	filled = 0
+	for _, ch := range caches {
+		if ch.isClosed {
+			continue
+		}
+		remPieces = append(remPieces, ch.getOutRangesPieces(ch.getReadersRanges())...)
+		filled += ch.filled.Load()
+	}
+	sort.Slice(remPieces, func(i, j int) bool {
+		return remPieces[i].Accessed < remPieces[j].Accessed
+	})
+
+	for _, p := range remPieces {
+		if filled <= capacity {
+			break
+		}
+		filled -= p.Size
+		p.cache.removePiece(p)
+	}
+}
@@ -0,0 +1,54 @@
+package torrstor
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// TestStorageCleanConcurrent runs clean and budget rebalance of storage while caches of two torrents are read
+func TestStorageCleanConcurrent(t *testing.T) {
+	stor := &Storage{caches: make(map[metainfo.Hash]*Cache), pool: NewBufferPool()}
+	stor.capacity.Store(benchPieceLength * 64)
+	for i := range 2 {
+		c := NewCache(0, stor)
+		c.pieceLength = benchPieceLength
+		stor.caches[metainfo.Hash{byte(i)}] = c
+	}
+
+	var wg sync.WaitGroup
+	for _, c := range stor.getCaches() {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := range 100 {
+				// as cache state and readers do
+				c.filled.Store(int64(i) * benchPieceLength / 4)
+				c.accessed.Store(time.Now().Unix())
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				stor.cleanPieces()
+				stor.updateCapacity()
+			}
+		}()
+	}
+	wg.Wait()
+	stor.updateCapacity()
+
+	if stor.isClean.Load() {
+		t.Error("clean flag is not reset")
+	}
+	for _, c := range stor.getCaches() {
+		if c.weight() != 2 {
+			t.Errorf("weight of accessed cache %d, want 2", c.weight())
+		}
+		if c.capacity.Load() != benchPieceLength*32 {
+			t.Errorf("capacity %d, want half of budget", c.capacity.Load())
+		}
+	}
+}
@@ -1,23 +1,31 @@
 package torr
 
 import (
+	"context"
 	"encoding/hex"
 	"errors"
 	"fmt"
-	"log"
-	"net"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/anacrolix/missinggo/v2/httptoo"
 	"github.com/anacrolix/torrent"
 
+	"server/metrics"
 	mt "server/mimetype"
 	sets "server/settings"
 	"server/torr/state"
 )
 
+// active streams, waited on shutdown
+var streams sync.WaitGroup
+
 func (t *Torrent) Stream(fileID int, req *http.Request, resp http.ResponseWriter) error {
+	streams.Add(1)
+	defer streams.Done()
+
 	if !t.GotInfo() {
 		http.NotFound(resp, req)
 		return errors.New("torrent don't get info")
@@ -47,7 +55,7 @@ func (t *Torrent) Stream(fileID int, req *http.Request, resp http.ResponseWriter
 		return fmt.Errorf("file with id %v not found", fileID)
 	}
 	if int64(sets.MaxSize) > 0 && file.Length() > int64(sets.MaxSize) {
-		log.Println("file", file.DisplayPath(), "size exceeded max allowed", sets.MaxSize, "bytes")
+		logger.Warn("file size exceeded max allowed", "hash", t.Hash().HexString(), "file", fileID, "path", file.DisplayPath(), "max_size", sets.MaxSize)
 		return fmt.Errorf("file size exceeded max allowed %d bytes", sets.MaxSize)
 	}
 
@@ -56,14 +64,8 @@ func (t *Torrent) Stream(fileID int, req *http.Request, resp http.ResponseWriter
 		reader.SetResponsive()
 	}
 
-	host, port, err := net.SplitHostPort(req.RemoteAddr)
-	if sets.BTsets.EnableDebug {
-		if err != nil {
-			log.Println("Connect client")
-		} else {
-			log.Println("Connect client", host, port)
-		}
-	}
+	clog := logger.With("hash", t.Hash().HexString(), "file", fileID, "remote", req.RemoteAddr)
+	clog.Debug("connect client")
 
 	sets.SetViewed(&sets.Viewed{Hash: t.Hash().HexString(), FileIndex: fileID})
 
@@ -71,17 +73,78 @@ func (t *Torrent) Stream(fileID int, req *http.Request, resp http.ResponseWriter
 	etag := hex.EncodeToString(fmt.Appendf(nil, "%s/%s", t.Hash().HexString(), file.Path()))
 	resp.Header().Set("ETag", httptoo.EncodeQuotedString(etag))
 
-	resp.Header().Set("Content-Type", mt.MimeTypeByPath(file.Path()).String())
+	mime := mt.MimeTypeByPath(file.Path())
+	resp.Header().Set("Content-Type", mime.String())
+	// set directly, some renderers need exact case of dlna headers
+	resp.Header()["transferMode.dlna.org"] = []string{mime.TransferMode()}
+	resp.Header()["contentFeatures.dlna.org"] = []string{mime.ContentFeatures()}
 
-	http.ServeContent(resp, req, file.Path(), time.Unix(t.Timestamp, 0), reader)
+	sw := metrics.StartStream(resp)
+	http.ServeContent(sw, req, file.Path(), time.Unix(t.Timestamp, 0), reader)
+	sw.End()
 
 	t.CloseReader(reader)
-	if sets.BTsets.EnableDebug {
-		if err != nil {
-			log.Println("Disconnect client")
-		} else {
-			log.Println("Disconnect client", host, port)
-		}
+	clog.Debug("disconnect client")
+	if req.Method != http.MethodHead {
+		t.saveSession(fileID, req, sw)
 	}
 	return nil
 }
+
+// saveSession writes stream session of file or hls segment to history and access log
+func (t *Torrent) saveSession(fileID int, req *http.Request, sw *metrics.StreamWriter) {
+	from, to := sw.Served()
+	s := &sets.StreamSession{
+		Hash:      t.Hash().HexString(),
+		FileIndex: fileID,
+		Remote:    req.RemoteAddr,
+		UserAgent: req.UserAgent(),
+		Range:     req.Header.Get("Range"),
+		From:      from,
+		To:        to,
+		Status:    sw.Status(),
+		BytesSent: sw.Sent(),
+		Start:     sw.Started().UnixMilli(),
+		Duration:  time.Since(sw.Started()).Seconds(),
+		Reason:    t.streamStopReason(req, sw),
+	}
+	logger.Info("stream session", "hash", s.Hash, "file", s.FileIndex, "remote", s.Remote, "user_agent", s.UserAgent,
+		"range", s.Range, "from", s.From, "to", s.To, "status", s.Status, "bytes_sent", s.BytesSent, "duration", s.Duration, "reason", s.Reason)
+	sets.AddStreamSession(s)
+}
+
+// streamStopReason tells why stream ended
+func (t *Torrent) streamStopReason(req *http.Request, sw *metrics.StreamWriter) string {
+	select {
+	case <-shutdownChan:
+		return "shutdown"
+	default:
+	}
+	switch {
+	case t.Stat == state.TorrentClosed:
+		return "torrent closed"
+	case req.Context().Err() != nil:
+		return "client closed"
+	case sw.Err() != nil:
+		return "write error: " + sw.Err().Error()
+	case sw.Status() >= http.StatusBadRequest:
+		return "status " + strconv.Itoa(sw.Status())
+	default:
+		return "completed"
+	}
+}
+
+// WaitStreams waits for active streams to finish until ctx is done
+func WaitStreams(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		streams.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
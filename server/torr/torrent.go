@@ -11,7 +11,7 @@ import (
 	"github.com/anacrolix/torrent"
 	"github.com/anacrolix/torrent/metainfo"
 
-	"log"
+	"server/media"
 	"server/settings"
 	"server/torr/state"
 	cacheSt "server/torr/storage/state"
@@ -48,6 +48,19 @@ type Torrent struct {
 	DurationSeconds float64
 	BitRate         string
 
+	// per torrent caps in kb, 0 - no cap
+	DownloadRateLimit int
+	UploadRateLimit   int
+	dlDebt            float64
+	upDebt            float64
+	dlPaused          bool
+	upPaused          bool
+
+	media   map[int]*media.Index
+	hls     map[int]*HLSIndex
+	subs    map[subsKey][]media.Cue
+	muMedia sync.Mutex
+
 	expiredTime time.Time
 
 	closed <-chan struct{}
@@ -168,7 +181,7 @@ func (t *Torrent) watch() {
 func (t *Torrent) progressEvent() {
 	if t.expired() {
 		if t.TorrentSpec != nil {
-			log.Println("Torrent close by timeout", t.TorrentSpec.InfoHash.HexString())
+			logger.Info("torrent close by timeout", "hash", t.TorrentSpec.InfoHash.HexString())
 		}
 		t.bt.RemoveTorrent(t.Hash())
 		return
@@ -187,6 +200,8 @@ func (t *Torrent) progressEvent() {
 		t.BytesReadUsefulData = st.BytesRead.Int64()
 		t.BytesWrittenData = st.BytesWritten.Int64()
 
+		t.limitRate(deltaDlBytes, deltaUpBytes, deltaTime)
+
 		if t.cache != nil {
 			t.PreloadedBytes = t.cache.GetState().Filled
 		}
@@ -198,6 +213,7 @@ func (t *Torrent) progressEvent() {
 
 	t.lastTimeSpeed = time.Now()
 	t.updateRA()
+	t.publishEvent()
 }
 
 func (t *Torrent) updateRA() {
@@ -284,10 +300,21 @@ func (t *Torrent) Close() bool {
 	t.bt.mu.Unlock()
 
 	t.drop()
+	// last event with closed state
+	t.publishEvent()
 	return true
 }
 
 func (t *Torrent) Status() *state.TorrentStatus {
+	return t.status(true)
+}
+
+// StatusLite returns status without files and peer and traffic counters
+func (t *Torrent) StatusLite() *state.TorrentStatus {
+	return t.status(false)
+}
+
+func (t *Torrent) status(full bool) *state.TorrentStatus {
 	t.muTorrent.Lock()
 	defer t.muTorrent.Unlock()
 
@@ -303,6 +330,9 @@ func (t *Torrent) Status() *state.TorrentStatus {
 	st.TorrentSize = t.Size
 	st.BitRate = t.BitRate
 	st.DurationSeconds = t.DurationSeconds
+	st.DownloadRateLimit = t.DownloadRateLimit
+	st.UploadRateLimit = t.UploadRateLimit
+	st.Release = t.release()
 
 	if t.TorrentSpec != nil {
 		st.Hash = t.TorrentSpec.InfoHash.HexString()
@@ -317,6 +347,13 @@ func (t *Torrent) Status() *state.TorrentStatus {
 		st.DownloadSpeed = t.DownloadSpeed
 		st.UploadSpeed = t.UploadSpeed
 
+		if t.Torrent.Info() != nil {
+			st.TorrentSize = t.Torrent.Length()
+		}
+		if !full {
+			return st
+		}
+
 		tst := t.Torrent.Stats()
 		st.BytesWritten = tst.BytesWritten.Int64()
 		st.BytesWrittenData = tst.BytesWrittenData.Int64()
@@ -336,8 +373,6 @@ func (t *Torrent) Status() *state.TorrentStatus {
 		st.HalfOpenPeers = tst.HalfOpenPeers
 
 		if t.Torrent.Info() != nil {
-			st.TorrentSize = t.Torrent.Length()
-
 			files := t.Files()
 			sort.Slice(files, func(i, j int) bool {
 				return utils2.CompareStrings(files[i].Path(), files[j].Path())
@@ -355,6 +390,42 @@ func (t *Torrent) Status() *state.TorrentStatus {
 	return st
 }
 
+// Release returns release info edited in torrent data, parsed from name and title otherwise
+func (t *Torrent) Release() *state.Release {
+	t.muTorrent.Lock()
+	defer t.muTorrent.Unlock()
+	return t.release()
+}
+
+func (t *Torrent) release() *state.Release {
+	// parsed info is not taken from data, so it follows name and title changes
+	if rel := getDataRelease(t.Data); rel != nil && rel.Edited {
+		return rel
+	}
+	name := ""
+	if t.Torrent != nil {
+		name = t.Torrent.Name()
+	} else if t.TorrentSpec != nil {
+		name = t.TorrentSpec.DisplayName
+	}
+	rel := utils2.ParseRelease(name)
+	rel.Fill(utils2.ParseRelease(t.Title))
+	if *rel == (state.Release{}) {
+		return nil
+	}
+	return rel
+}
+
+// FileStats returns files of torrent with info, files saved in torrent data otherwise
+func (t *Torrent) FileStats() []*state.TorrentFileStat {
+	if files := t.Status().FileStats; len(files) > 0 {
+		return files
+	}
+	t.muTorrent.Lock()
+	defer t.muTorrent.Unlock()
+	return getDataFiles(t.Data)
+}
+
 func (t *Torrent) CacheState() *cacheSt.CacheState {
 	if t.Torrent != nil && t.cache != nil {
 		st := t.cache.GetState()
@@ -2,22 +2,24 @@ package utils
 
 import (
 	"bufio"
-	"log"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"server/logs"
 	"server/settings"
 
 	"github.com/anacrolix/torrent/iplist"
 )
 
+var logger = logs.For("torr")
+
 func ReadBlockedIP() (ranger iplist.Ranger, err error) {
 	buf, err := os.ReadFile(filepath.Join(settings.Path, "blocklist"))
 	if err != nil {
 		return nil, err
 	}
-	log.Println("Read block list...")
+	logger.Info("read block list")
 	scanner := bufio.NewScanner(strings.NewReader(string(buf)))
 	var ranges []iplist.Range
 	for scanner.Scan() {
@@ -32,7 +34,7 @@ func ReadBlockedIP() (ranger iplist.Ranger, err error) {
 	err = scanner.Err()
 	if len(ranges) > 0 {
 		ranger = iplist.New(ranges)
-		log.Println("Readed ranges:", len(ranges))
+		logger.Info("block list read", "ranges", len(ranges))
 	}
 	return
 }
@@ -88,6 +88,16 @@ func PeerIDRandom(peer string) string {
 	return peer + base32.StdEncoding.EncodeToString(randomBytes)[:20-len(peer)]
 }
 
+// SetLimit changes limit of running limiter, 0 - inf
+func SetLimit(l *rate.Limiter, i int) {
+	if i > 0 {
+		l.SetBurst(max(i, 16*1024))
+		l.SetLimit(rate.Limit(i))
+	} else {
+		l.SetLimit(rate.Inf)
+	}
+}
+
 func Limit(i int) *rate.Limiter {
 	l := rate.NewLimiter(rate.Inf, 0)
 	if i > 0 {
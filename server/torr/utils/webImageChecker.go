@@ -9,8 +9,6 @@ import (
 	"strings"
 
 	"golang.org/x/image/webp"
-
-	"log"
 )
 
 func CheckImgUrl(link string) bool {
@@ -19,7 +17,7 @@ func CheckImgUrl(link string) bool {
 	}
 	resp, err := http.Get(link)
 	if err != nil {
-		log.Println("Error check image:", err)
+		logger.Warn("check image", "url", link, "err", err)
 		return false
 	}
 	defer resp.Body.Close()
@@ -29,7 +27,7 @@ func CheckImgUrl(link string) bool {
 		_, _, err = image.Decode(resp.Body)
 	}
 	if err != nil {
-		log.Println("Error decode image:", err)
+		logger.Warn("decode image", "url", link, "err", err)
 		return false
 	}
 	return err == nil
@@ -0,0 +1,321 @@
+package torznab
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent/metainfo"
+
+	"server/logs"
+	"server/settings"
+)
+
+var logger = logs.For("search")
+
+var client = &http.Client{Timeout: 30 * time.Second}
+
+// Result is search candidate merged by infohash, link, title and category are accepted by torrents add action
+type Result struct {
+	Link     string   `json:"link"`
+	Hash     string   `json:"hash,omitempty"` // empty if indexer gives only torrent file link
+	Title    string   `json:"title"`
+	Category string   `json:"category,omitempty"` // movie, tv, music, other
+	Size     int64    `json:"size,omitempty"`
+	Seeders  int      `json:"seeders"`
+	Peers    int      `json:"peers"`
+	PubDate  int64    `json:"pub_date,omitempty"` // unix time
+	Indexers []string `json:"indexers"`
+}
+
+type rss struct {
+	XMLName     xml.Name
+	Code        string `xml:"code,attr"`
+	Description string `xml:"description,attr"`
+	Items       []item `xml:"channel>item"`
+}
+
+type item struct {
+	Title      string   `xml:"title"`
+	Guid       string   `xml:"guid"`
+	Link       string   `xml:"link"`
+	Size       int64    `xml:"size"`
+	PubDate    string   `xml:"pubDate"`
+	Categories []string `xml:"category"`
+	Indexer    string   `xml:"jackettindexer"`
+	Enclosure  struct {
+		URL    string `xml:"url,attr"`
+		Length int64  `xml:"length,attr"`
+	} `xml:"enclosure"`
+	Attrs []struct {
+		Name  string `xml:"name,attr"`
+		Value string `xml:"value,attr"`
+	} `xml:"attr"`
+}
+
+func (i *item) attr(name string) string {
+	for _, a := range i.Attrs {
+		if strings.EqualFold(a.Name, name) {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// Search queries all configured endpoints with query and categories (torznab ids, comma separated, optional),
+// results are merged by infohash and sorted by seeders. Error is returned only if all endpoints failed
+func Search(ctx context.Context, query, cat string) ([]*Result, error) {
+	var endpoints []*settings.TorznabConfig
+	for _, e := range settings.BTsets.TorznabUrls {
+		if e != nil && e.Host != "" {
+			endpoints = append(endpoints, e)
+		}
+	}
+	if len(endpoints) == 0 {
+		return nil, errors.New("no torznab endpoints in settings")
+	}
+
+	var wg sync.WaitGroup
+	items := make([][]item, len(endpoints))
+	errs := make([]error, len(endpoints))
+	for i, e := range endpoints {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			items[i], errs[i] = search(ctx, e, query, cat)
+		}()
+	}
+	wg.Wait()
+
+	// merge in settings order, so first endpoint gives title and link
+	var (
+		lastErr error
+		order   []*Result
+	)
+	merged := make(map[string]*Result)
+	for i, e := range endpoints {
+		if errs[i] != nil {
+			logger.Warn("torznab search", "indexer", endpointName(e), "err", errs[i])
+			lastErr = errs[i]
+			continue
+		}
+		logger.Debug("torznab search", "indexer", endpointName(e), "query", query, "items", len(items[i]))
+		for _, it := range items[i] {
+			r := toResult(&it, endpointName(e))
+			if r == nil {
+				continue
+			}
+			key := r.Hash
+			if key == "" {
+				key = r.Link
+			}
+			if old, ok := merged[key]; ok {
+				merge(old, r)
+				continue
+			}
+			merged[key] = r
+			order = append(order, r)
+		}
+	}
+	if !slices.Contains(errs, nil) {
+		return nil, lastErr
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return order[i].Seeders > order[j].Seeders
+	})
+	return order, nil
+}
+
+func search(ctx context.Context, e *settings.TorznabConfig, query, cat string) ([]item, error) {
+	u, err := apiURL(e.Host)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	q.Set("t", "search")
+	q.Set("q", query)
+	if e.Key != "" {
+		q.Set("apikey", e.Key)
+	}
+	if cat != "" {
+		q.Set("cat", cat)
+	}
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		// keep api key out of logs and errors
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			return nil, uerr.Err
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(resp.Status)
+	}
+
+	var feed rss
+	if err = xml.NewDecoder(io.LimitReader(resp.Body, 16<<20)).Decode(&feed); err != nil {
+		return nil, err
+	}
+	if feed.XMLName.Local == "error" {
+		return nil, fmt.Errorf("torznab error %s: %s", feed.Code, feed.Description)
+	}
+	return feed.Items, nil
+}
+
+// apiURL returns api url of host, /api is appended if missing
+func apiURL(host string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(host))
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("wrong torznab host: %s", u.Redacted())
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	if !strings.HasSuffix(u.Path, "/api") {
+		u.Path += "/api"
+	}
+	return u, nil
+}
+
+func endpointName(e *settings.TorznabConfig) string {
+	if e.Name != "" {
+		return e.Name
+	}
+	if u, err := url.Parse(e.Host); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return e.Host
+}
+
+func toResult(it *item, indexer string) *Result {
+	r := &Result{
+		Title:   strings.TrimSpace(it.Title),
+		Size:    it.Size,
+		Seeders: atoi(it.attr("seeders")),
+		Peers:   atoi(it.attr("peers")),
+	}
+	if it.Indexer != "" {
+		indexer = it.Indexer
+	}
+	r.Indexers = []string{indexer}
+	if r.Size == 0 {
+		r.Size = it.Enclosure.Length
+	}
+	if r.Size == 0 {
+		r.Size, _ = strconv.ParseInt(it.attr("size"), 10, 64)
+	}
+	if t, err := parseDate(it.PubDate); err == nil {
+		r.PubDate = t.Unix()
+	}
+
+	// magnet is preferred, torrent file links are resolved by torrents add
+	for _, link := range []string{it.attr("magneturl"), it.Link, it.Enclosure.URL, it.Guid} {
+		if !strings.HasPrefix(link, "magnet:") {
+			continue
+		}
+		if mag, err := metainfo.ParseMagnetUri(link); err == nil {
+			r.Link = link
+			r.Hash = mag.InfoHash.HexString()
+			break
+		}
+	}
+	if hash := strings.ToLower(it.attr("infohash")); len(hash) == 40 {
+		r.Hash = hash
+	}
+	if r.Link == "" {
+		for _, link := range []string{it.Link, it.Enclosure.URL} {
+			if strings.HasPrefix(link, "http://") || strings.HasPrefix(link, "https://") {
+				r.Link = link
+				break
+			}
+		}
+	}
+	if r.Link == "" && r.Hash != "" {
+		r.Link = "magnet:?xt=urn:btih:" + r.Hash
+	}
+	if r.Link == "" {
+		return nil
+	}
+
+	cats := it.Categories
+	if c := it.attr("category"); c != "" {
+		cats = append(cats, c)
+	}
+	r.Category = category(cats)
+	return r
+}
+
+// merge adds found by other indexer result to r
+func merge(r, o *Result) {
+	r.Seeders = max(r.Seeders, o.Seeders)
+	r.Peers = max(r.Peers, o.Peers)
+	if r.Size == 0 {
+		r.Size = o.Size
+	}
+	if r.PubDate == 0 || (o.PubDate != 0 && o.PubDate < r.PubDate) {
+		r.PubDate = o.PubDate
+	}
+	if r.Category == "" {
+		r.Category = o.Category
+	}
+	if !strings.HasPrefix(r.Link, "magnet:") && strings.HasPrefix(o.Link, "magnet:") {
+		r.Link = o.Link
+	}
+	for _, name := range o.Indexers {
+		if !slices.Contains(r.Indexers, name) {
+			r.Indexers = append(r.Indexers, name)
+		}
+	}
+}
+
+// category maps torznab category ids to torrent category
+func category(cats []string) string {
+	for _, c := range cats {
+		id := atoi(c)
+		switch {
+		case id >= 2000 && id < 3000:
+			return "movie"
+		case id >= 5000 && id < 6000:
+			return "tv"
+		case id >= 3000 && id < 4000:
+			return "music"
+		}
+	}
+	if len(cats) > 0 {
+		return "other"
+	}
+	return ""
+}
+
+func parseDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	t, err := time.Parse(time.RFC1123Z, s)
+	if err != nil {
+		t, err = time.Parse(time.RFC1123, s)
+	}
+	return t, err
+}
+
+func atoi(s string) int {
+	i, _ := strconv.Atoi(strings.TrimSpace(s))
+	return i
+}
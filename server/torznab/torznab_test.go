@@ -0,0 +1,184 @@
+package torznab
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"server/settings"
+)
+
+const (
+	hash1 = "0123456789abcdef0123456789abcdef01234567"
+	hash2 = "1123456789abcdef0123456789abcdef01234567"
+	hash3 = "2123456789abcdef0123456789abcdef01234567"
+)
+
+func feed(items ...string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:torznab="http://torznab.com/schemas/2015/feed"><channel>` + strings.Join(items, "") + `</channel></rss>`
+}
+
+func feedItem(title, link string, seeders int, attrs ...string) string {
+	s := fmt.Sprintf(`<item><title>%s</title><link>%s</link><size>1000</size><category>2000</category>`+
+		`<pubDate>Mon, 02 Jan 2006 15:04:05 +0000</pubDate><torznab:attr name="seeders" value="%d"/>`, title, link, seeders)
+	for i := 0; i+1 < len(attrs); i += 2 {
+		s += fmt.Sprintf(`<torznab:attr name="%s" value="%s"/>`, attrs[i], attrs[i+1])
+	}
+	return s + "</item>"
+}
+
+func magnet(hash string) string {
+	return "magnet:?xt=urn:btih:" + hash + "&amp;dn=x"
+}
+
+// indexer serves body and records queries
+type indexer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	queries []url.Values
+}
+
+func newIndexer(t *testing.T, status int, body string) *indexer {
+	ix := &indexer{}
+	ix.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ix.mu.Lock()
+		ix.queries = append(ix.queries, r.URL.Query())
+		ix.mu.Unlock()
+		if r.URL.Path != "/api" {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(ix.Close)
+	return ix
+}
+
+func setEndpoints(t *testing.T, endpoints ...*settings.TorznabConfig) {
+	old := settings.BTsets
+	t.Cleanup(func() { settings.BTsets = old })
+	settings.BTsets = &settings.BTSets{TorznabUrls: endpoints}
+}
+
+func TestSearchMerge(t *testing.T) {
+	a := newIndexer(t, 200, feed(
+		feedItem("Movie 2020 1080p", magnet(hash1), 5),
+		feedItem("Other", "http://a/dl/2", 1, "infohash", strings.ToUpper(hash2)),
+	))
+	b := newIndexer(t, 200, feed(
+		feedItem("Movie.2020.1080p", "http://b/dl/1", 10, "infohash", hash1, "peers", "20"),
+		feedItem("Third", magnet(hash3), 7),
+		feedItem("Other", magnet(hash2), 2),
+	))
+	setEndpoints(t, &settings.TorznabConfig{Name: "A", Host: a.URL, Key: "secret"}, &settings.TorznabConfig{Host: b.URL + "/api/"})
+
+	res, err := Search(context.Background(), "movie", "2000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var hashes []string
+	for _, r := range res {
+		hashes = append(hashes, r.Hash)
+	}
+	if !slices.Equal(hashes, []string{hash1, hash3, hash2}) {
+		t.Fatalf("results %v, want merged by hash and sorted by seeders", hashes)
+	}
+	m := res[0]
+	bName := strings.TrimPrefix(b.URL, "http://")
+	if m.Title != "Movie 2020 1080p" || m.Link != "magnet:?xt=urn:btih:"+hash1+"&dn=x" ||
+		m.Seeders != 10 || m.Peers != 20 || m.Category != "movie" || !slices.Equal(m.Indexers, []string{"A", bName}) {
+		t.Errorf("wrong merged result %+v", m)
+	}
+	// magnet of other indexer replaces http link
+	if res[2].Link != "magnet:?xt=urn:btih:"+hash2+"&dn=x" || res[2].Seeders != 2 {
+		t.Errorf("wrong merged result %+v", res[2])
+	}
+
+	q := a.queries[0]
+	if q.Get("t") != "search" || q.Get("q") != "movie" || q.Get("cat") != "2000" || q.Get("apikey") != "secret" {
+		t.Errorf("wrong query %v", q)
+	}
+	if b.queries[0].Has("apikey") {
+		t.Error("api key is sent to endpoint without key")
+	}
+}
+
+func TestSearchErrors(t *testing.T) {
+	good := newIndexer(t, 200, feed(feedItem("Movie", magnet(hash1), 5)))
+	errFeed := newIndexer(t, 200, `<?xml version="1.0" encoding="UTF-8"?><error code="100" description="Invalid API Key"/>`)
+	failed := newIndexer(t, 500, "")
+
+	for _, tc := range []struct {
+		name      string
+		endpoints []*indexer
+		err       string
+		results   int
+	}{
+		{"error feed and good", []*indexer{errFeed, good}, "", 1},
+		{"failed and good", []*indexer{failed, good}, "", 1},
+		{"all failed", []*indexer{failed, errFeed}, "Invalid API Key", 0},
+	} {
+		var endpoints []*settings.TorznabConfig
+		for _, ix := range tc.endpoints {
+			endpoints = append(endpoints, &settings.TorznabConfig{Host: ix.URL, Key: "secret"})
+		}
+		setEndpoints(t, endpoints...)
+		res, err := Search(context.Background(), "movie", "")
+		if tc.err == "" && err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+		}
+		if tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+			t.Errorf("%s: error %v, want %q", tc.name, err, tc.err)
+		}
+		if err != nil && strings.Contains(err.Error(), "secret") {
+			t.Errorf("%s: api key in error %v", tc.name, err)
+		}
+		if len(res) != tc.results {
+			t.Errorf("%s: %d results, want %d", tc.name, len(res), tc.results)
+		}
+	}
+
+	setEndpoints(t)
+	if _, err := Search(context.Background(), "movie", ""); err == nil {
+		t.Error("search without endpoints has no error")
+	}
+}
+
+func TestToResult(t *testing.T) {
+	mag := "magnet:?xt=urn:btih:" + hash1
+	for _, tc := range []struct {
+		name, xml  string
+		link, hash string
+	}{
+		{"magnet attr over http link", `<link>http://x/1</link><torznab:attr name="magneturl" value="` + mag + `"/>`, mag, hash1},
+		{"magnet guid over enclosure", `<guid>` + mag + `</guid><enclosure url="https://x/1.torrent"/>`, mag, hash1},
+		{"http link with hash", `<link>http://x/1</link><torznab:attr name="infohash" value="` + hash2 + `"/>`, "http://x/1", hash2},
+		{"enclosure only", `<enclosure url="https://x/1.torrent"/>`, "https://x/1.torrent", ""},
+		{"hash only", `<torznab:attr name="infohash" value="` + hash3 + `"/>`, "magnet:?xt=urn:btih:" + hash3, hash3},
+		{"no link", `<link>ftp://x/1</link>`, "", ""},
+	} {
+		var it item
+		if err := xml.Unmarshal([]byte(`<item xmlns:torznab="http://torznab.com/schemas/2015/feed">`+tc.xml+`</item>`), &it); err != nil {
+			t.Fatal(err)
+		}
+		r := toResult(&it, "A")
+		if tc.link == "" {
+			if r != nil {
+				t.Errorf("%s: result %+v, want nil", tc.name, r)
+			}
+			continue
+		}
+		if r == nil || r.Link != tc.link || r.Hash != tc.hash {
+			t.Errorf("%s: result %+v, want link %q hash %q", tc.name, r, tc.link, tc.hash)
+		}
+	}
+}
@@ -6,6 +6,9 @@ import (
 )
 
 func GetScheme(c *gin.Context) string {
+	if c.Request.TLS != nil {
+		return "https"
+	}
 	url := location.Get(c)
 	if url == nil {
 		return "http"
@@ -0,0 +1,161 @@
+package utils
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"server/torr/state"
+)
+
+var (
+	reGroupPrefix   = regexp.MustCompile(`^\[([^\]]+)\]\s*`)
+	reGroupSuffix   = regexp.MustCompile(`-([A-Za-z0-9]+)$`)
+	reLeadEpisode   = regexp.MustCompile(`^(\d{1,3})(?:\.\s|\s-\s|$)`)
+	reSeasonEpisode = regexp.MustCompile(`(?i)\bs(\d{1,2})\s?e(\d{1,4})\b`)
+	reCrossEpisode  = regexp.MustCompile(`\b(\d{1,2})x(\d{2,3})\b`)
+	reSeason        = regexp.MustCompile(`(?i)(?:\bs|\bseason\s?|(?:^|[\s\[(])сезон\s?)(\d{1,2})\b|\b(\d{1,2})\s?сезон`)
+	reEpisode       = regexp.MustCompile(`(?i)\b(?:e|ep|episode\s?)(\d{1,4})\b|\s-\s(\d{1,3})(?:v\d)?(?:\s|$)`)
+	reYear          = regexp.MustCompile(`\b(19\d{2}|20\d{2})\b`)
+	reResolution    = regexp.MustCompile(`(?i)\b(2160|1440|1080|720|576|480)[pi]\b|\b(4k|uhd)\b`)
+	reCodec         = regexp.MustCompile(`(?i)\b(x26[45]|h\s?26[45]|hevc|avc|xvid|divx|av1|vp9)\b`)
+	reReleaseTag    = regexp.MustCompile(`(?i)\b(blu-?ray|bdrip|brrip|bdremux|remux|web-?dl|web-?rip|hdtv|dvdrip|hdrip|dvd5|dvd9|proper|repack|hdr10|hdr|imax|extended|uncut)\b`)
+	reSpaces        = regexp.MustCompile(`\s+`)
+)
+
+// ParseRelease extracts title, year, season, episode, resolution, codec and group from release name of torrent or file,
+// name of file is expected without dir
+func ParseRelease(name string) *state.Release {
+	r := new(state.Release)
+	s := strings.TrimSpace(name)
+	if GetMimeType(s) != "*/*" {
+		s = strings.TrimSuffix(s, filepath.Ext(s))
+	}
+
+	// [Group] Title - 01 [1080p] or Title.S01E01.1080p-GROUP
+	if m := reGroupPrefix.FindStringSubmatch(s); m != nil {
+		r.Group = m[1]
+		s = s[len(m[0]):]
+	} else if m := reGroupSuffix.FindStringSubmatch(s); m != nil && !isReleaseWord(m[1]) {
+		r.Group = m[1]
+		s = strings.TrimSuffix(s, m[0])
+	}
+	// 01. Pilot, 01 - Pilot
+	lead := false
+	if m := reLeadEpisode.FindStringSubmatch(s); m != nil {
+		r.Episode, _ = strconv.Atoi(m[1])
+		s = s[len(m[0]):]
+		lead = true
+	}
+
+	s = strings.NewReplacer(".", " ", "_", " ").Replace(s)
+	s = reSpaces.ReplaceAllString(s, " ")
+
+	// title ends at first marker, year is not marker at start of name: 1917 1080p
+	start, cut := 0, len(s)
+	mark := func(loc []int) {
+		if loc == nil {
+			return
+		}
+		if loc[0] == 0 {
+			// E05 Pilot
+			start = loc[1]
+		} else if loc[0] < cut {
+			cut = loc[0]
+		}
+	}
+
+	if m := reSeasonEpisode.FindStringSubmatchIndex(s); m != nil {
+		r.Season = atoi(s[m[2]:m[3]])
+		r.Episode = atoi(s[m[4]:m[5]])
+		mark(m)
+	} else if m := reCrossEpisode.FindStringSubmatchIndex(s); m != nil {
+		r.Season = atoi(s[m[2]:m[3]])
+		r.Episode = atoi(s[m[4]:m[5]])
+		mark(m)
+	} else {
+		if m := reSeason.FindStringSubmatchIndex(s); m != nil {
+			r.Season = atoi(submatch(s, m))
+			mark(m)
+		}
+		if m := reEpisode.FindStringSubmatchIndex(s); m != nil && !lead {
+			r.Episode = atoi(submatch(s, m))
+			mark(m)
+		}
+	}
+	if m := reResolution.FindStringSubmatchIndex(s); m != nil {
+		if m[2] >= 0 {
+			r.Resolution = s[m[2]:m[3]] + "p"
+		} else {
+			r.Resolution = "2160p"
+		}
+		mark(m)
+	}
+	if m := reCodec.FindStringSubmatchIndex(s); m != nil {
+		r.Codec = strings.ReplaceAll(strings.ToLower(s[m[2]:m[3]]), " ", "")
+		mark(m)
+	}
+	mark(reReleaseTag.FindStringIndex(s))
+
+	// last year before other markers: Blade Runner 2049 2017 1080p, first one after them otherwise
+	maxYear := time.Now().Year() + 1
+	yearPos := -1
+	for _, m := range reYear.FindAllStringIndex(s, -1) {
+		y := atoi(s[m[0]:m[1]])
+		if m[0] == 0 || y > maxYear {
+			continue
+		}
+		if m[0] < cut || r.Year == 0 {
+			r.Year = y
+			yearPos = m[0]
+		}
+		if m[0] > cut {
+			break
+		}
+	}
+	if yearPos > 0 && yearPos < cut {
+		cut = yearPos
+	}
+	if i := strings.IndexAny(s, "[("); i > 0 && i < cut {
+		cut = i
+	}
+
+	if start > cut {
+		start = cut
+	}
+	r.Title = strings.Trim(s[start:cut], " -[](){}")
+	return r
+}
+
+func isReleaseWord(s string) bool {
+	return reResolution.MatchString(s) || reCodec.MatchString(s) || reReleaseTag.MatchString(s) ||
+		strings.EqualFold(s, "dl") || strings.EqualFold(s, "rip") || reYear.MatchString(s)
+}
+
+// submatch returns first matched group of alternatives
+func submatch(s string, m []int) string {
+	for i := 2; i+1 < len(m); i += 2 {
+		if m[i] >= 0 {
+			return s[m[i]:m[i+1]]
+		}
+	}
+	return ""
+}
+
+func atoi(s string) int {
+	i, _ := strconv.Atoi(s)
+	return i
+}
+
+// FileRelease parses name of file in torrent, fields missing in file name are taken from release of torrent
+func FileRelease(torrent *state.Release, path string) *state.Release {
+	r := ParseRelease(filepath.Base(path))
+	if torrent != nil && r.Episode > 0 && torrent.Title != "" {
+		// episode names are not titles: 01. Pilot
+		r.Title = torrent.Title
+	}
+	r.Fill(torrent)
+	return r
+}
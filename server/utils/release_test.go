@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"testing"
+
+	"server/torr/state"
+)
+
+func TestParseRelease(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		want state.Release
+	}{
+		{"Breaking.Bad.S02E03.720p.BluRay.x264-DEMAND.mkv", state.Release{Title: "Breaking Bad", Season: 2, Episode: 3, Resolution: "720p", Codec: "x264", Group: "DEMAND"}},
+		{"[SubsPlease] Frieren - 05 (1080p) [ABCD1234].mkv", state.Release{Title: "Frieren", Episode: 5, Resolution: "1080p", Group: "SubsPlease"}},
+		{"Blade Runner 2049 2017 1080p WEB-DL", state.Release{Title: "Blade Runner 2049", Year: 2017, Resolution: "1080p"}},
+		{"1917 (2019) 2160p HDR", state.Release{Title: "1917", Year: 2019, Resolution: "2160p"}},
+		{"The Matrix (1999) [4K]", state.Release{Title: "The Matrix", Year: 1999, Resolution: "2160p"}},
+		{"Сериал 2 сезон 720p", state.Release{Title: "Сериал", Season: 2, Resolution: "720p"}},
+		{"Show.3x07.HDTV", state.Release{Title: "Show", Season: 3, Episode: 7}},
+		{"01. Pilot.mkv", state.Release{Title: "Pilot", Episode: 1}},
+		{"E05 Pilot", state.Release{Title: "Pilot", Episode: 5}},
+		{"Movie.2999.1080p", state.Release{Title: "Movie 2999", Resolution: "1080p"}},
+	} {
+		if got := ParseRelease(tc.name); *got != tc.want {
+			t.Errorf("%q: got %+v, want %+v", tc.name, *got, tc.want)
+		}
+	}
+}
+
+func TestFileRelease(t *testing.T) {
+	torrent := ParseRelease("Friends.S01.1080p.x265-GRP")
+	for _, tc := range []struct {
+		torrent *state.Release
+		path    string
+		want    state.Release
+	}{
+		{torrent, "Friends/01. Pilot.mkv", state.Release{Title: "Friends", Season: 1, Episode: 1, Resolution: "1080p", Codec: "x265", Group: "GRP"}},
+		{torrent, "Friends/Friends.S01E02.720p.mkv", state.Release{Title: "Friends", Season: 1, Episode: 2, Resolution: "720p", Codec: "x265", Group: "GRP"}},
+		{ParseRelease("Best Movies 1080p"), "Movies/Heat.1995.mkv", state.Release{Title: "Heat", Year: 1995, Resolution: "1080p"}},
+		{nil, "Heat.1995.mkv", state.Release{Title: "Heat", Year: 1995}},
+	} {
+		if got := FileRelease(tc.torrent, tc.path); *got != tc.want {
+			t.Errorf("%q: got %+v, want %+v", tc.path, *got, tc.want)
+		}
+	}
+}
+
+func TestReleaseString(t *testing.T) {
+	for _, tc := range []struct {
+		rel  state.Release
+		want string
+	}{
+		{state.Release{Title: "Breaking Bad", Season: 2, Episode: 3, Resolution: "720p"}, "Breaking Bad S02E03 [720p]"},
+		{state.Release{Title: "Heat", Year: 1995}, "Heat (1995)"},
+		{state.Release{Title: "Show", Season: 1}, "Show S01"},
+		{state.Release{Episode: 5}, "E05"},
+	} {
+		if got := tc.rel.String(); got != tc.want {
+			t.Errorf("got %q, want %q", got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"server/torr"
+	"server/web/auth"
+)
+
+// pieces in one line of piece map
+const pieceMapWidth = 64
+
+// setupDebugRoute adds /debug group with pprof and diagnostics of client, caches and readers
+func setupDebugRoute(route gin.IRouter) {
+	debug := route.Group("/debug", auth.CheckAuthOrLocal())
+
+	debug.GET("/pprof/*name", debugPprof)
+	debug.POST("/pprof/*name", debugPprof)
+
+	debug.GET("/client", debugClient)
+	debug.GET("/pieces/:hash", debugPieces)
+	debug.GET("/readers", debugReaders)
+}
+
+func debugPprof(c *gin.Context) {
+	switch c.Param("name") {
+	case "/cmdline":
+		pprof.Cmdline(c.Writer, c.Request)
+	case "/profile":
+		pprof.Profile(c.Writer, c.Request)
+	case "/symbol":
+		pprof.Symbol(c.Writer, c.Request)
+	case "/trace":
+		pprof.Trace(c.Writer, c.Request)
+	default:
+		// index and named profiles: heap, goroutine, block...
+		pprof.Index(c.Writer, c.Request)
+	}
+}
+
+// debugClient godoc
+//
+//	@Summary		Torrent client status
+//	@Description	Status dump of anacrolix torrent client: dht, peers and torrents. Enabled by --debug.
+//
+//	@Tags			Debug
+//
+//	@Produce		text/plain
+//	@Success		200	"Client status"
+//	@Router			/debug/client [get]
+func debugClient(c *gin.Context) {
+	c.Header("Content-Type", "text/plain; charset=utf-8")
+	c.Status(200)
+	torr.WriteStatus(c.Writer)
+}
+
+// debugPieces godoc
+//
+//	@Summary		Piece map of torrent cache
+//	@Description	Cache pieces of active torrent, one char per piece: "." not in cache, "+" loading, "#" complete, "R" reader position; and reader ranges. Enabled by --debug.
+//
+//	@Tags			Debug
+//
+//	@Param			hash	path	string	true	"Torrent hash"
+//
+//	@Produce		text/plain
+//	@Success		200	"Piece map"
+//	@Router			/debug/pieces/{hash} [get]
+func debugPieces(c *gin.Context) {
+	// only active torrents, GetTorrent would start torrent from DB
+	hash := strings.ToLower(c.Param("hash"))
+	var t *torr.Torrent
+	for _, at := range torr.ListActiveTorrents() {
+		if at.Hash().HexString() == hash {
+			t = at
+			break
+		}
+	}
+	if t == nil {
+		c.String(http.StatusNotFound, "torrent is not active\n")
+		return
+	}
+	st := t.CacheState()
+	if st == nil {
+		c.String(http.StatusNotFound, "torrent has no info yet\n")
+		return
+	}
+
+	readerPieces := make(map[int]bool)
+	for _, r := range st.Readers {
+		readerPieces[r.Reader] = true
+	}
+
+	c.Header("Content-Type", "text/plain; charset=utf-8")
+	c.Status(200)
+	w := bufio.NewWriter(c.Writer)
+	fmt.Fprintf(w, "%s %s\n", st.Hash, st.Torrent.Name)
+	fmt.Fprintf(w, "pieces: %d x %d, cached: %d, capacity: %d, filled: %d, disk filled: %d\n\n",
+		st.PiecesCount, st.PiecesLength, len(st.Pieces), st.Capacity, st.Filled, st.DiskFilled)
+	var line strings.Builder
+	for i := range st.PiecesCount {
+		if i%pieceMapWidth == 0 {
+			if i > 0 {
+				fmt.Fprintf(w, "%8d %s\n", i-pieceMapWidth, line.String())
+			}
+			line.Reset()
+		}
+		p, ok := st.Pieces[i]
+		switch {
+		case readerPieces[i]:
+			line.WriteByte('R')
+		case !ok:
+			line.WriteByte('.')
+		case p.Completed:
+			line.WriteByte('#')
+		default:
+			line.WriteByte('+')
+		}
+	}
+	if line.Len() > 0 {
+		fmt.Fprintf(w, "%8d %s\n", st.PiecesCount-line.Len(), line.String())
+	}
+	fmt.Fprintf(w, "\nreaders: %d\n", len(st.Readers))
+	for _, r := range st.Readers {
+		fmt.Fprintf(w, "  piece %d, range %d-%d\n", r.Reader, r.Start, r.End)
+	}
+	w.Flush()
+}
+
+// debugReaders godoc
+//
+//	@Summary		Readers of active torrents
+//	@Description	Reader positions and ranges in pieces of all active torrents. Enabled by --debug.
+//
+//	@Tags			Debug
+//
+//	@Produce		text/plain
+//	@Success		200	"Readers"
+//	@Router			/debug/readers [get]
+func debugReaders(c *gin.Context) {
+	c.Header("Content-Type", "text/plain; charset=utf-8")
+	c.Status(200)
+	w := bufio.NewWriter(c.Writer)
+	for _, t := range torr.ListActiveTorrents() {
+		st := t.CacheState()
+		if st == nil {
+			continue
+		}
+		fmt.Fprintf(w, "%s %s: %d readers\n", st.Hash, st.Torrent.Name, len(st.Readers))
+		for _, r := range st.Readers {
+			fmt.Fprintf(w, "  piece %d/%d, range %d-%d\n", r.Reader, st.PiecesCount, r.Start, r.End)
+		}
+	}
+	w.Flush()
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"server/torr"
+)
+
+const eventsKeepAlive = 15 * time.Second
+
+// events godoc
+//
+//	@Summary		Stream of torrent status updates
+//	@Description	Server-Sent Events with torrent status and cache state, sent every second by progress ticker. First event of torrent has all selected fields, next ones only changed fields.
+//
+//	@Tags			API
+//
+//	@Param			hash			query	string	false	"Comma separated torrent hashes, all torrents if empty"
+//	@Param			fields			query	string	false	"Comma separated status fields, all if empty, e.g. download_speed,preloaded_bytes"
+//	@Param			cache			query	bool	false	"Send cache state"
+//	@Param			cache_fields	query	string	false	"Comma separated cache state fields, all if empty, e.g. Filled,Readers"
+//
+//	@Produce		text/event-stream
+//	@Success		200	"Events with hash, status and cache objects"
+//	@Router			/events [get]
+func events(c *gin.Context) {
+	hashes := splitQuery(c.Query("hash"))
+	for i := range hashes {
+		hashes[i] = strings.ToLower(hashes[i])
+	}
+	fields := splitQuery(c.Query("fields"))
+	cacheFields := splitQuery(c.Query("cache_fields"))
+	// cache without value is true
+	cacheStr, cache := c.GetQuery("cache")
+	if cache && cacheStr != "" {
+		var err error
+		if cache, err = strconv.ParseBool(cacheStr); err != nil {
+			c.AbortWithError(http.StatusBadRequest, errors.New("\"cache\" is wrong"))
+			return
+		}
+	}
+	cache = cache || len(cacheFields) > 0
+
+	sub := torr.Subscribe(hashes, cache)
+	defer torr.Unsubscribe(sub)
+
+	// last sent fields by hash
+	lastStatus := make(map[string]map[string]any)
+	lastCache := make(map[string]map[string]any)
+
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case <-torr.ShutdownRequested():
+			return false
+		case <-keepAlive.C:
+			c.SSEvent("ping", "")
+			return true
+		case ev := <-sub.C:
+			msg := gin.H{"hash": ev.Hash}
+			if ev.Status != nil {
+				if delta := fieldsDelta(ev.Status, fields, lastStatus, ev.Hash); len(delta) > 0 {
+					msg["status"] = delta
+				}
+			}
+			if ev.Cache != nil {
+				if delta := fieldsDelta(ev.Cache, cacheFields, lastCache, ev.Hash); len(delta) > 0 {
+					msg["cache"] = delta
+				}
+			}
+			if len(msg) > 1 {
+				c.SSEvent("status", msg)
+			}
+			return true
+		}
+	})
+}
+
+func splitQuery(s string) []string {
+	var ret []string
+	for v := range strings.SplitSeq(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			ret = append(ret, v)
+		}
+	}
+	return ret
+}
+
+// fieldsDelta returns selected json fields of v changed since last call for hash
+func fieldsDelta(v any, fields []string, last map[string]map[string]any, hash string) map[string]any {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	cur := make(map[string]any)
+	if err = json.Unmarshal(buf, &cur); err != nil {
+		return nil
+	}
+	if len(fields) > 0 {
+		sel := make(map[string]any, len(fields))
+		for _, f := range fields {
+			if val, ok := cur[f]; ok {
+				sel[f] = val
+			}
+		}
+		cur = sel
+	}
+
+	prev := last[hash]
+	last[hash] = cur
+	delta := make(map[string]any)
+	for k, val := range cur {
+		if p, ok := prev[k]; !ok || !reflect.DeepEqual(p, val) {
+			delta[k] = val
+		}
+	}
+	// omitted fields became empty
+	for k := range prev {
+		if _, ok := cur[k]; !ok {
+			delta[k] = nil
+		}
+	}
+	return delta
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"server/torr"
+	"server/web/api/utils"
+)
+
+// ffp godoc
+//
+//	@Summary		Media info of torrent file
+//	@Description	Reports streams, codecs, languages, duration and bitrate of file in ffprobe json format. Result is cached in torrent data.
+//
+//	@Tags			API
+//
+//	@Param			hash		path	string	true	"Torrent hash"
+//	@Param			id			path	string	true	"File index in torrent"
+//
+//	@Produce		json
+//	@Success		200	{object}	media.ProbeData	"Data returned from probe"
+//	@Router			/ffp/{hash}/{id} [get]
+func ffp(c *gin.Context) {
+	hash := c.Param("hash")
+	indexStr := c.Param("id")
+
+	if hash == "" || indexStr == "" {
+		c.AbortWithError(http.StatusNotFound, errors.New("link should not be empty"))
+		return
+	}
+
+	index, err := strconv.Atoi(indexStr)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("\"index\" is wrong"))
+		return
+	}
+
+	if _, ok := linkAuth(c, hash, indexStr); !ok {
+		return
+	}
+	// cached probe doesn't need torrent connection
+	if spec, err := utils.ParseLink(hash); err == nil {
+		if pd := torr.GetProbe(spec.InfoHash.HexString(), index); pd != nil {
+			c.JSON(200, pd)
+			return
+		}
+	}
+
+	tor, ok := loadTorrent(c, hash, indexStr)
+	if !ok {
+		return
+	}
+
+	pd, err := tor.Probe(index)
+	if err != nil {
+		c.AbortWithError(http.StatusUnsupportedMediaType, err)
+		return
+	}
+	c.JSON(200, pd)
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"server/torr"
+)
+
+// hls godoc
+//
+//	@Summary		HLS playlist and segments of torrent file
+//	@Description	Serves VOD media playlist (index.m3u8), init section (init.mp4) and segments of MPEG-TS or fragmented MP4 file without transcoding.
+//
+//	@Tags			API
+//
+//	@Param			hash		path	string	true	"Torrent hash"
+//	@Param			id			path	string	true	"File index in torrent"
+//	@Param			name		path	string	true	"index.m3u8, init.mp4 or segment name"
+//
+//	@Produce		application/vnd.apple.mpegurl
+//	@Success		200	"Playlist or segment data"
+//	@Router			/hls/{hash}/{id}/{name} [get]
+func hls(c *gin.Context) {
+	hash := c.Param("hash")
+	indexStr := c.Param("id")
+	name := c.Param("name")
+
+	tor, ok := loadTorrent(c, hash, indexStr)
+	if !ok {
+		return
+	}
+
+	index, err := strconv.Atoi(indexStr)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("\"index\" is wrong"))
+		return
+	}
+
+	idx, err := tor.HLS(index)
+	if err != nil {
+		c.AbortWithError(http.StatusUnsupportedMediaType, err)
+		return
+	}
+
+	if name == "index.m3u8" {
+		c.Header("Cache-Control", "no-cache")
+		// segments are requested by player without auth, so they are signed as playlist
+		query := strings.TrimPrefix(signQuery(tor.Hash().HexString(), indexStr), "&")
+		c.Data(200, "application/vnd.apple.mpegurl", []byte(idx.Playlist(query)))
+		return
+	}
+
+	seg := idx.Segment(name)
+	if seg == nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	contentType := "video/mp2t"
+	if idx.Type == torr.HLSTypeFMP4 {
+		contentType = "video/mp4"
+	}
+	if err := tor.ServeHLSSegment(index, idx, seg, contentType, c.Request, c.Writer); err != nil && !c.Writer.Written() {
+		c.AbortWithError(http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"server/logs"
+	"server/torr"
+)
+
+// Action: get, set
+type logsReqJS struct {
+	requestI
+	Levels map[string]string `json:"levels,omitempty"` // subsystem: main, settings, torr, torrstor, web, dlna, search; level: debug, info, warn, error
+	File   *bool             `json:"file,omitempty"`   // write log to rotating torrserver.log in config dir
+}
+
+type logsJS struct {
+	Levels map[string]string `json:"levels"`
+	File   string            `json:"file,omitempty"` // path of log file, empty if log is not written to file
+}
+
+// logsHandler godoc
+//
+//	@Summary		Get / Set log levels and log file
+//	@Description	Allow to get or change log level of subsystems and writing log to file at runtime. Changes are not saved, LogLevels and LogFile settings are restored on settings change or restart.
+//
+//	@Tags			API
+//
+//	@Param			request	body	logsReqJS	true	"Logs request. Available params for action: get, set"
+//
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	logsJS	"Current levels and log file"
+//	@Router			/logs [post]
+func logsHandler(c *gin.Context) {
+	var req logsReqJS
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	switch req.Action {
+	case "get":
+	case "set":
+		for name, level := range req.Levels {
+			if err := logs.SetLevel(name, level); err != nil {
+				c.AbortWithError(http.StatusBadRequest, err)
+				return
+			}
+		}
+		if req.File != nil {
+			if err := torr.SetLogFile(*req.File); err != nil {
+				c.AbortWithError(http.StatusInternalServerError, err)
+				return
+			}
+		}
+	default:
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	c.JSON(200, logsJS{Levels: logs.Levels(), File: logs.File()})
+}
@@ -17,6 +17,7 @@ import (
 	"server/torr"
 	"server/torr/state"
 	"server/utils"
+	"server/web/auth"
 
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
@@ -44,8 +45,12 @@ func allPlayList(c *gin.Context) {
 		if tr.Poster != "" {
 			list += " tvg-logo=\"" + tr.Poster + "\""
 		}
-		list += " type=\"playlist\"," + tr.Title + "\n"
-		list += host + "/stream/" + url.PathEscape(tr.Title) + ".m3u?link=" + tr.TorrentSpec.InfoHash.HexString() + "&m3u&fn=file.m3u\n"
+		title := tr.Title
+		if rel := tr.Release(); rel != nil && rel.Title != "" {
+			title = rel.String()
+		}
+		list += " type=\"playlist\"," + title + "\n"
+		list += host + "/stream/" + url.PathEscape(tr.Title) + ".m3u?link=" + tr.TorrentSpec.InfoHash.HexString() + signQuery(tr.TorrentSpec.InfoHash.HexString(), "") + "&m3u&fn=file.m3u\n"
 		hash += tr.Hash().HexString()
 	}
 
@@ -123,31 +128,54 @@ func getM3uList(tor *state.TorrentStatus, host string, fromLast bool) string {
 			from = pos
 		}
 	}
+	single := len(utils.GetPlayableFiles(*tor)) == 1
 	for i, f := range tor.FileStats {
 		if i >= from {
 			if utils.GetMimeType(f.Path) != "*/*" {
-				fn := filepath.Base(f.Path)
-				if fn == "" {
-					fn = f.Path
-				}
-				m3u += "#EXTINF:0," + fn + "\n"
+				m3u += "#EXTINF:0," + m3uTitle(tor, f, single) + "\n"
 				fileNamesakes := findFileNamesakes(tor.FileStats, f) // find external media with same name (audio/subtiles tracks)
 				if fileNamesakes != nil {
 					m3u += "#EXTVLCOPT:input-slave="         // include VLC option for external media
 					for _, namesake := range fileNamesakes { // include play-links to external media, with # splitter
 						sname := filepath.Base(namesake.Path)
-						m3u += host + "/stream/" + url.PathEscape(sname) + "?link=" + tor.Hash + "&index=" + fmt.Sprint(namesake.Id) + "&play#"
+						m3u += host + "/stream/" + url.PathEscape(sname) + "?link=" + tor.Hash + "&index=" + fmt.Sprint(namesake.Id) + signQuery(tor.Hash, fmt.Sprint(namesake.Id)) + "&play#"
 					}
 					m3u += "\n"
 				}
 				name := filepath.Base(f.Path)
-				m3u += host + "/stream/" + url.PathEscape(name) + "?link=" + tor.Hash + "&index=" + fmt.Sprint(f.Id) + "&play\n"
+				m3u += host + "/stream/" + url.PathEscape(name) + "?link=" + tor.Hash + "&index=" + fmt.Sprint(f.Id) + signQuery(tor.Hash, fmt.Sprint(f.Id)) + "&play\n"
 			}
 		}
 	}
 	return m3u
 }
 
+// signQuery returns exp and sig params of link if signed links are enabled
+func signQuery(hash, index string) string {
+	if !sets.BTsets.SignLinks {
+		return ""
+	}
+	return "&" + auth.SignLink(hash, index)
+}
+
+// m3uTitle returns release title of file with episode or year in name, of single media file of torrent or file name
+func m3uTitle(tor *state.TorrentStatus, f *state.TorrentFileStat, single bool) string {
+	name := filepath.Base(f.Path)
+	if name == "" {
+		name = f.Path
+	}
+	if tor.Release == nil || tor.Release.Title == "" {
+		return name
+	}
+	if single {
+		return tor.Release.String()
+	}
+	if rel := utils.ParseRelease(name); rel.Episode == 0 && rel.Year == 0 {
+		return name
+	}
+	return utils.FileRelease(tor.Release, f.Path).String()
+}
+
 func findFileNamesakes(files []*state.TorrentFileStat, file *state.TorrentFileStat) []*state.TorrentFileStat {
 	// find files with the same name in torrent
 	name := filepath.Base(strings.TrimSuffix(file.Path, filepath.Ext(file.Path)))
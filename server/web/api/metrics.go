@@ -0,0 +1,28 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"server/metrics"
+	"server/torr"
+)
+
+// metricsHandler godoc
+//
+//	@Summary		Prometheus metrics
+//	@Description	Metrics of active torrents, their caches, http streams and go runtime in prometheus text format.
+//
+//	@Tags			API
+//
+//	@Produce		text/plain
+//	@Success		200	"Metrics in prometheus text exposition format"
+//	@Router			/metrics [get]
+func metricsHandler(c *gin.Context) {
+	c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.Status(200)
+	w := metrics.NewWriter(c.Writer)
+	torr.WriteMetrics(w)
+	metrics.WriteStreams(w)
+	metrics.WriteRuntime(w)
+	w.Flush()
+}
@@ -28,8 +28,6 @@ import (
 func play(c *gin.Context) {
 	hash := c.Param("hash")
 	indexStr := c.Param("id")
-	notAuth := c.GetBool("auth_required") && c.GetString(gin.AuthUserKey) == ""
-
 	if hash == "" || indexStr == "" {
 		c.AbortWithError(http.StatusNotFound, errors.New("link should not be empty"))
 		return
@@ -41,6 +39,11 @@ func play(c *gin.Context) {
 		return
 	}
 
+	notAuth, ok := linkAuth(c, hash, indexStr)
+	if !ok {
+		return
+	}
+
 	tor := torr.GetTorrent(spec.InfoHash.HexString())
 	if tor == nil && notAuth {
 		c.Header("WWW-Authenticate", "Basic realm=Authorization Required")
@@ -83,3 +86,44 @@ func play(c *gin.Context) {
 
 	tor.Stream(index, c.Request, c.Writer)
 }
+
+// loadTorrent returns torrent of hash with info for request of its file, loading it from DB if needed,
+// unknown torrents need auth and signed links need signature or user. Response is aborted if torrent can't be loaded
+func loadTorrent(c *gin.Context, hash, index string) (*torr.Torrent, bool) {
+	notAuth, ok := linkAuth(c, hash, index)
+	if !ok {
+		return nil, false
+	}
+
+	spec, err := utils.ParseLink(hash)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return nil, false
+	}
+
+	tor := torr.GetTorrent(spec.InfoHash.HexString())
+	if tor == nil && notAuth {
+		c.Header("WWW-Authenticate", "Basic realm=Authorization Required")
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return nil, false
+	}
+
+	if tor == nil {
+		c.AbortWithError(http.StatusNotFound, errors.New("error get torrent"))
+		return nil, false
+	}
+
+	if tor.Stat == state.TorrentInDB {
+		tor, err = torr.AddTorrent(spec, tor.Title, tor.Poster, tor.Data, tor.Category)
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return nil, false
+		}
+	}
+
+	if !tor.GotInfo() {
+		c.AbortWithError(http.StatusInternalServerError, errors.New("timeout connection torrent"))
+		return nil, false
+	}
+	return tor, true
+}
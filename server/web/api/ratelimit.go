@@ -0,0 +1,54 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"server/torr"
+)
+
+// Action: get, set
+type rateLimitReqJS struct {
+	requestI
+	Download int `json:"download"` // in kb, 0 - inf
+	Upload   int `json:"upload"`   // in kb, 0 - inf
+}
+
+// rateLimit godoc
+//
+//	@Summary		Get / Set global rate limits
+//	@Description	Allow to get or change download and upload limits of running client without reconnect. Limits set here are temporary: they are not saved to settings, limits of settings or active bandwidth profile are restored on settings change, profile switch or restart. Change DownloadRateLimit and UploadRateLimit settings to keep them.
+//
+//	@Tags			API
+//
+//	@Param			request	body	rateLimitReqJS	true	"Rate limit request. Available params for action: get, set"
+//
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	rateLimitReqJS	"Current limits"
+//	@Router			/ratelimit [post]
+func rateLimit(c *gin.Context) {
+	var req rateLimitReqJS
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	switch req.Action {
+	case "get":
+	case "set":
+		if req.Download < 0 || req.Upload < 0 {
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+		torr.SetRateLimits(req.Download, req.Upload)
+	default:
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	var resp rateLimitReqJS
+	resp.Download, resp.Upload = torr.GetRateLimits()
+	c.JSON(200, resp)
+}
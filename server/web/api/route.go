@@ -1,38 +1,81 @@
 package api
 
-import "github.com/gin-gonic/gin"
+import (
+	"github.com/gin-gonic/gin"
+
+	"server/logs"
+	sets "server/settings"
+	"server/web/auth"
+)
+
+var logger = logs.For("web")
 
 type requestI struct {
 	Action string `json:"action,omitempty"`
 }
 
+// SetupPlayRoute adds play handler only, used by dlna media server listener
+func SetupPlayRoute(route gin.IRouter) {
+	route.HEAD("/play/:hash/:id", play)
+	route.GET("/play/:hash/:id", play)
+}
+
 func SetupRoute(route gin.IRouter) {
-	route.GET("/shutdown", shutdown)
-	route.GET("/shutdown/*reason", shutdown)
+	authorized := route.Group("/", auth.CheckAuth())
+
+	authorized.GET("/shutdown", shutdown)
+	authorized.GET("/shutdown/*reason", shutdown)
+
+	authorized.POST("/settings", settings)
+
+	authorized.POST("/torrents", torrents)
+
+	authorized.POST("/torrent/upload", torrentUpload)
+
+	authorized.POST("/cache", cache)
 
-	route.POST("/settings", settings)
+	authorized.POST("/tokens", tokens)
 
-	route.POST("/torrents", torrents)
+	authorized.POST("/ratelimit", rateLimit)
 
-	route.POST("/torrent/upload", torrentUpload)
+	authorized.GET("/events", events)
 
-	route.POST("/cache", cache)
+	authorized.GET("/metrics", metricsHandler)
 
+	authorized.POST("/logs", logsHandler)
+
+	authorized.GET("/search", search)
+
+	authorized.GET("/torznab/search", torznabSearch)
+
+	// stream handlers check auth by themselves, known torrents are played without auth
 	route.HEAD("/stream", stream)
 	route.GET("/stream", stream)
 
 	route.HEAD("/stream/*fname", stream)
 	route.GET("/stream/*fname", stream)
 
-	route.HEAD("/play/:hash/:id", play)
-	route.GET("/play/:hash/:id", play)
+	SetupPlayRoute(route)
+
+	route.HEAD("/hls/:hash/:id/:name", hls)
+	route.GET("/hls/:hash/:id/:name", hls)
+
+	route.GET("/ffp/:hash/:id", ffp)
+
+	route.GET("/subs/:hash/:id", subs)
+
+	authorized.POST("/viewed", viewed)
+
+	authorized.POST("/sessions", sessions)
 
-	route.POST("/viewed", viewed)
+	authorized.GET("/playlistall/all.m3u", allPlayList)
 
-	route.GET("/playlistall/all.m3u", allPlayList)
+	authorized.GET("/playlist", playList)
+	authorized.GET("/playlist/*fname", playList)
 
-	route.GET("/playlist", playList)
-	route.GET("/playlist/*fname", playList)
+	authorized.GET("/download/:size", download)
 
-	route.GET("/download/:size", download)
+	if sets.Debug {
+		setupDebugRoute(route)
+	}
 }
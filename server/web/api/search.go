@@ -0,0 +1,63 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	sets "server/settings"
+)
+
+// search godoc
+//
+//	@Summary		Search saved torrents
+//	@Description	Full text search over title, category, torrent name and file paths of torrents saved in DB. Every word matches as prefix, words not found in title are looked up in file paths and matching files are returned.
+//
+//	@Tags			API
+//
+//	@Param			query		query	string	false	"Search words"
+//	@Param			category	query	string	false	"Category of torrent"
+//	@Param			min_size	query	int		false	"Min torrent size in bytes"
+//	@Param			max_size	query	int		false	"Max torrent size in bytes"
+//	@Param			from		query	int		false	"Added at or after, unix time"
+//	@Param			to			query	int		false	"Added at or before, unix time"
+//	@Param			limit		query	int		false	"Max results"
+//
+//	@Produce		json
+//	@Success		200	{array}	sets.SearchResult
+//	@Router			/search [get]
+func search(c *gin.Context) {
+	q := &sets.SearchQuery{
+		Query:    c.Query("query"),
+		Category: c.Query("category"),
+	}
+	var limit int64
+	for name, val := range map[string]*int64{"min_size": &q.MinSize, "max_size": &q.MaxSize, "from": &q.From, "to": &q.To, "limit": &limit} {
+		if err := queryInt(c, name, val); err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+	}
+	q.Limit = int(limit)
+
+	list := sets.SearchTorrents(q)
+	if list == nil {
+		list = []*sets.SearchResult{}
+	}
+	c.JSON(200, list)
+}
+
+// queryInt parses query param to val if it is set
+func queryInt(c *gin.Context, name string, val *int64) error {
+	s := c.Query(name)
+	if s == "" {
+		return nil
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return err
+	}
+	*val = v
+	return nil
+}
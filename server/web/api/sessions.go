@@ -0,0 +1,48 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	sets "server/settings"
+)
+
+// Action: list, clear
+type sessionsReqJS struct {
+	requestI
+	Hash  string `json:"hash,omitempty"`  // sessions of torrent, all if empty
+	Limit int    `json:"limit,omitempty"` // max sessions, all if 0
+}
+
+// sessions godoc
+//
+//	@Summary		List / Clear stream sessions history
+//	@Description	Stream sessions newest first: remote address, user agent, torrent hash, file id, requested ranges, bytes sent, duration and stop reason.
+//
+//	@Tags			API
+//
+//	@Param			request	body	sessionsReqJS	true	"Sessions request. Available params for action: list, clear"
+//
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{array}	sets.StreamSession
+//	@Router			/sessions [post]
+func sessions(c *gin.Context) {
+	var req sessionsReqJS
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	switch req.Action {
+	case "list":
+		c.JSON(200, sets.ListStreamSessions(req.Hash, req.Limit))
+	case "clear":
+		sets.ClearStreamSessions()
+		c.Status(200)
+	default:
+		c.AbortWithStatus(http.StatusBadRequest)
+	}
+}
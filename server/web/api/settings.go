@@ -6,6 +6,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
 
+	"server/dlna"
 	sets "server/settings"
 	"server/torr"
 )
@@ -16,6 +17,11 @@ type setsReqJS struct {
 	Sets *sets.BTSets `json:"sets,omitempty"`
 }
 
+type settingsJS struct {
+	*sets.BTSets
+	ActiveProfile string // bandwidth profile active by schedule, empty out of schedule
+}
+
 // settings godoc
 //
 //	@Summary		Get / Set server settings
@@ -27,7 +33,7 @@ type setsReqJS struct {
 //
 //	@Accept			json
 //	@Produce		json
-//	@Success		200	{object}	sets.BTSets	"Settings JSON or nothing. Depends on what action has been asked."
+//	@Success		200	{object}	settingsJS	"Settings JSON or nothing. Depends on what action has been asked."
 //	@Router			/settings [post]
 func settings(c *gin.Context) {
 	var req setsReqJS
@@ -38,14 +44,16 @@ func settings(c *gin.Context) {
 	}
 
 	if req.Action == "get" {
-		c.JSON(200, sets.BTsets)
+		c.JSON(200, settingsJS{sets.BTsets, torr.ActiveBandwidthProfile()})
 		return
 	} else if req.Action == "set" {
 		torr.SetSettings(req.Sets)
+		dlna.Apply()
 		c.Status(200)
 		return
 	} else if req.Action == "def" {
 		torr.SetDefSettings()
+		dlna.Apply()
 		c.Status(200)
 		return
 	}
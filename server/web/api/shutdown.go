@@ -3,7 +3,6 @@ package api
 import (
 	"net/http"
 	"strings"
-	"time"
 
 	sets "server/settings"
 	"server/torr"
@@ -13,7 +12,7 @@ import (
 
 // shutdown godoc
 // @Summary		Shuts down server
-// @Description	Gracefully shuts down server after 1 second.
+// @Description	Gracefully shuts down server, active streams are given time to finish.
 //
 // @Tags			API
 //
@@ -26,8 +25,5 @@ func shutdown(c *gin.Context) {
 		return
 	}
 	c.Status(200)
-	go func() {
-		time.Sleep(1000)
-		torr.Shutdown()
-	}()
+	torr.Shutdown()
 }
@@ -6,10 +6,12 @@ import (
 	"strconv"
 	"strings"
 
+	sets "server/settings"
 	"server/torr"
 	"server/torr/state"
 	utils2 "server/utils"
 	"server/web/api/utils"
+	"server/web/auth"
 
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
@@ -67,6 +69,17 @@ func stream(c *gin.Context) {
 
 	notAuth := c.GetBool("auth_required") && c.GetString(gin.AuthUserKey) == ""
 
+	// with signed links only signature or user authorizes play, valid signature does it for its hash and index only
+	if sets.BTsets.SignLinks && (play || m3u) && c.GetString(gin.AuthUserKey) == "" {
+		if !signedLink(c, link, indexStr) {
+			c.Header("WWW-Authenticate", "Basic realm=Authorization Required")
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		notAuth = false
+		save, stat = false, false
+	}
+
 	if notAuth && (play || m3u) {
 		streamNoAuth(c)
 		return
@@ -261,3 +274,27 @@ func streamNoAuth(c *gin.Context) {
 	c.Header("WWW-Authenticate", "Basic realm=Authorization Required")
 	c.AbortWithStatus(http.StatusUnauthorized)
 }
+
+// linkAuth returns whether request of torrent file is not authorized, with signed links only signature or user authorizes it.
+// Response is aborted if signature is wrong
+func linkAuth(c *gin.Context, link, index string) (notAuth bool, ok bool) {
+	notAuth = c.GetBool("auth_required") && c.GetString(gin.AuthUserKey) == ""
+	if !sets.BTsets.SignLinks || c.GetString(gin.AuthUserKey) != "" {
+		return notAuth, true
+	}
+	if !signedLink(c, link, index) {
+		c.Header("WWW-Authenticate", "Basic realm=Authorization Required")
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return notAuth, false
+	}
+	return false, true
+}
+
+// signedLink checks exp and sig params of link, link is hash or magnet of torrent
+func signedLink(c *gin.Context, link, index string) bool {
+	spec, err := utils.ParseLink(link)
+	if err != nil {
+		return false
+	}
+	return auth.CheckSign(spec.InfoHash.HexString(), index, c.Query("exp"), c.Query("sig"))
+}
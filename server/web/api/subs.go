@@ -0,0 +1,65 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"server/media"
+)
+
+// subs godoc
+//
+//	@Summary		Subtitles of torrent file in WebVTT
+//	@Description	Converts SRT, ASS/SSA subtitle file or embedded text track of MKV file to WebVTT.
+//
+//	@Tags			API
+//
+//	@Param			hash		path	string	true	"Torrent hash"
+//	@Param			id			path	string	true	"File index in torrent"
+//	@Param			track		query	string	false	"Stream index of embedded subtitles, as reported by /ffp"
+//
+//	@Produce		text/vtt
+//	@Success		200	"WebVTT subtitles"
+//	@Router			/subs/{hash}/{id} [get]
+func subs(c *gin.Context) {
+	hash := c.Param("hash")
+	indexStr := c.Param("id")
+
+	if hash == "" || indexStr == "" {
+		c.AbortWithError(http.StatusNotFound, errors.New("link should not be empty"))
+		return
+	}
+
+	index, err := strconv.Atoi(indexStr)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("\"index\" is wrong"))
+		return
+	}
+
+	track := -1
+	if trackStr := c.Query("track"); trackStr != "" {
+		track, err = strconv.Atoi(trackStr)
+		if err != nil || track < 0 {
+			c.AbortWithError(http.StatusBadRequest, errors.New("\"track\" is wrong"))
+			return
+		}
+	}
+
+	tor, ok := loadTorrent(c, hash, indexStr)
+	if !ok {
+		return
+	}
+
+	cues, err := tor.Subtitles(index, track)
+	if err != nil {
+		c.AbortWithError(http.StatusUnsupportedMediaType, err)
+		return
+	}
+
+	c.Header("Content-Type", "text/vtt; charset=utf-8")
+	c.Status(http.StatusOK)
+	media.WriteVTT(c.Writer, cues)
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"server/web/auth"
+)
+
+// Action: list, add, rem
+type tokensReqJS struct {
+	requestI
+	Token string `json:"token,omitempty"`
+}
+
+// tokens godoc
+//
+//	@Summary		List / Add / Remove api tokens
+//	@Description	Manage api tokens of authorized user. Token is accepted in "Authorization: Bearer" header or in "token" query param.
+//
+//	@Tags			API
+//
+//	@Param			request	body	tokensReqJS	true	"Tokens request. Available params for action: list, add, rem"
+//
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{array}	string	"List of tokens or new token"
+//	@Router			/tokens [post]
+func tokens(c *gin.Context) {
+	var req tokensReqJS
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	user := c.GetString(gin.AuthUserKey)
+	switch req.Action {
+	case "list":
+		{
+			c.JSON(200, auth.ListTokens(user))
+		}
+	case "add":
+		{
+			token, err := auth.AddToken(user)
+			if err != nil {
+				c.AbortWithError(http.StatusBadRequest, err)
+				return
+			}
+			c.JSON(200, []string{token})
+		}
+	case "rem":
+		{
+			if err := auth.RemToken(user, req.Token); err != nil {
+				c.AbortWithError(http.StatusBadRequest, err)
+				return
+			}
+			c.Status(200)
+		}
+	default:
+		c.AbortWithStatus(http.StatusBadRequest)
+	}
+}
@@ -2,10 +2,9 @@ package api
 
 import (
 	"net/http"
+	"strconv"
 	"strings"
 
-	"log"
-	set "server/settings"
 	"server/torr"
 	"server/torr/state"
 	"server/web/api/utils"
@@ -14,7 +13,7 @@ import (
 	"github.com/pkg/errors"
 )
 
-// Action: add, get, set, rem, list, drop
+// Action: add, get, set, limit, rem, list, drop
 type torrReqJS struct {
 	requestI
 	Link     string `json:"link,omitempty"`
@@ -24,16 +23,27 @@ type torrReqJS struct {
 	Poster   string `json:"poster,omitempty"`
 	Data     string `json:"data,omitempty"`
 	SaveToDB bool   `json:"save_to_db,omitempty"`
+
+	DownloadRateLimit int `json:"download_rate_limit,omitempty"` // in kb, 0 - no cap
+	UploadRateLimit   int `json:"upload_rate_limit,omitempty"`   // in kb, 0 - no cap
+
+	// list: category and title (substring) filter too
+	Stat   *state.TorrentStat `json:"stat,omitempty"`
+	Sort   string             `json:"sort,omitempty"`  // timestamp, size, title, viewed
+	Order  string             `json:"order,omitempty"` // asc, desc
+	Offset int                `json:"offset,omitempty"`
+	Limit  int                `json:"limit,omitempty"`
+	Lite   bool               `json:"lite,omitempty"` // without files and peers
 }
 
 // torrents godoc
 //
 //	@Summary		Handle torrents informations
-//	@Description	Allow to list, add, remove, get, set, drop, wipe torrents on server. The action depends of what has been asked.
+//	@Description	Allow to list, add, remove, get, set, limit, drop, wipe torrents on server. The action depends of what has been asked.
 //
 //	@Tags			API
 //
-//	@Param			request	body	torrReqJS	true	"Torrent request. Available params for action: add, get, set, rem, list, drop, wipe. link required for add, hash required for get, set, rem, drop."
+//	@Param			request	body	torrReqJS	true	"Torrent request. Available params for action: add, get, set, limit, rem, list, drop, wipe. link required for add, hash required for get, set, limit, rem, drop. limit accepts download_rate_limit and upload_rate_limit in kb (0 - no cap), caps are saved and kept approximately by pausing torrent data transfer each second. list accepts category, title, stat, sort, order, offset, limit, lite."
 //
 //	@Accept			json
 //	@Produce		json
@@ -60,13 +70,17 @@ func torrents(c *gin.Context) {
 		{
 			setTorrent(req, c)
 		}
+	case "limit":
+		{
+			limitTorrent(req, c)
+		}
 	case "rem":
 		{
 			remTorrent(req, c)
 		}
 	case "list":
 		{
-			listTorrents(c)
+			listTorrents(req, c)
 		}
 	case "drop":
 		{
@@ -85,33 +99,28 @@ func addTorrent(req torrReqJS, c *gin.Context) {
 		return
 	}
 
-	log.Println("add torrent", req.Link)
+	clog := logger.With("remote", c.Request.RemoteAddr)
+	clog.Info("add torrent", "link", req.Link)
 	req.Link = strings.ReplaceAll(req.Link, "&amp;", "&")
 	torrSpec, err := utils.ParseLink(req.Link)
 	if err != nil {
-		log.Println("error parse link:", err)
+		clog.Error("parse link", "link", req.Link, "err", err)
 		c.AbortWithError(http.StatusBadRequest, err)
 		return
 	}
 
+	clog = clog.With("hash", torrSpec.InfoHash.HexString())
 	tor, err := torr.AddTorrent(torrSpec, req.Title, req.Poster, req.Data, req.Category)
-
-	if tor.Data != "" && set.BTsets.EnableDebug {
-		log.Println("torrent data:", tor.Data)
-	}
-	if tor.Category != "" && set.BTsets.EnableDebug {
-		log.Println("torrent category:", tor.Category)
-	}
-
 	if err != nil {
-		log.Println("error add torrent:", err)
+		clog.Error("add torrent", "err", err)
 		c.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
+	clog.Debug("torrent added", "category", tor.Category, "data", tor.Data)
 
 	go func() {
 		if !tor.GotInfo() {
-			log.Println("error add torrent:", "timeout connection get torrent info")
+			clog.Error("add torrent: timeout connection get torrent info")
 			return
 		}
 
@@ -156,6 +165,19 @@ func setTorrent(req torrReqJS, c *gin.Context) {
 	c.Status(200)
 }
 
+func limitTorrent(req torrReqJS, c *gin.Context) {
+	if req.Hash == "" {
+		c.AbortWithError(http.StatusBadRequest, errors.New("hash is empty"))
+		return
+	}
+	if req.DownloadRateLimit < 0 || req.UploadRateLimit < 0 {
+		c.AbortWithError(http.StatusBadRequest, errors.New("rate limit is wrong"))
+		return
+	}
+	torr.SetTorrentLimits(req.Hash, req.DownloadRateLimit, req.UploadRateLimit)
+	c.Status(200)
+}
+
 func remTorrent(req torrReqJS, c *gin.Context) {
 	if req.Hash == "" {
 		c.AbortWithError(http.StatusBadRequest, errors.New("hash is empty"))
@@ -165,15 +187,28 @@ func remTorrent(req torrReqJS, c *gin.Context) {
 	c.Status(200)
 }
 
-func listTorrents(c *gin.Context) {
-	list := torr.ListTorrent()
+func listTorrents(req torrReqJS, c *gin.Context) {
+	list, total := torr.QueryTorrents(&torr.ListQuery{
+		Category: req.Category,
+		Title:    req.Title,
+		Stat:     req.Stat,
+		Sort:     req.Sort,
+		Order:    req.Order,
+		Offset:   req.Offset,
+		Limit:    req.Limit,
+	})
+	c.Header("X-Total-Count", strconv.Itoa(total))
 	if len(list) == 0 {
 		c.JSON(200, []*state.TorrentStatus{})
 		return
 	}
 	var stats []*state.TorrentStatus
 	for _, tr := range list {
-		stats = append(stats, tr.Status())
+		if req.Lite {
+			stats = append(stats, tr.StatusLite())
+		} else {
+			stats = append(stats, tr.Status())
+		}
 	}
 	c.JSON(200, stats)
 }
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"server/torznab"
+)
+
+// torznabSearch godoc
+//
+//	@Summary		Search torrents by torznab indexers
+//	@Description	Query all torznab endpoints of settings, results are merged by infohash and sorted by seeders. Link, title and category of result can be passed to torrents add action.
+//
+//	@Tags			API
+//
+//	@Param			query	query	string	true	"Search query"
+//	@Param			cat		query	string	false	"Torznab category ids, comma separated"
+//
+//	@Produce		json
+//	@Success		200	{array}	torznab.Result
+//	@Router			/torznab/search [get]
+func torznabSearch(c *gin.Context) {
+	query := c.Query("query")
+	if query == "" {
+		c.AbortWithError(http.StatusBadRequest, errors.New("query is empty"))
+		return
+	}
+	list, err := torznab.Search(c.Request.Context(), query, c.Query("cat"))
+	if err != nil {
+		c.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+	if list == nil {
+		list = []*torznab.Result{}
+	}
+	c.JSON(200, list)
+}
@@ -3,8 +3,6 @@ package api
 import (
 	"net/http"
 
-	"log"
-	set "server/settings"
 	"server/torr"
 	"server/web/api/utils"
 
@@ -57,38 +55,33 @@ func torrentUpload(c *gin.Context) {
 	}
 	var tor *torr.Torrent
 	for name, file := range form.File {
-		log.Println("add .torrent", name)
+		clog := logger.With("remote", c.Request.RemoteAddr, "name", name)
+		clog.Info("add .torrent")
 
 		torrFile, err := file[0].Open()
 		if err != nil {
-			log.Println("error upload torrent:", err)
+			clog.Error("upload torrent", "err", err)
 			continue
 		}
 		defer torrFile.Close()
 
 		spec, err := utils.ParseFile(torrFile)
 		if err != nil {
-			log.Println("error upload torrent:", err)
+			clog.Error("upload torrent", "err", err)
 			continue
 		}
 
+		clog = clog.With("hash", spec.InfoHash.HexString())
 		tor, err = torr.AddTorrent(spec, title, poster, data, category)
-
-		if tor.Data != "" && set.BTsets.EnableDebug {
-			log.Println("torrent data:", tor.Data)
-		}
-		if tor.Category != "" && set.BTsets.EnableDebug {
-			log.Println("torrent category:", tor.Category)
-		}
-
 		if err != nil {
-			log.Println("error upload torrent:", err)
+			clog.Error("upload torrent", "err", err)
 			continue
 		}
+		clog.Debug("torrent added", "category", tor.Category, "data", tor.Data)
 
 		go func() {
 			if !tor.GotInfo() {
-				log.Println("error add torrent:", "timeout connection torrent")
+				clog.Error("add torrent: timeout connection torrent")
 				return
 			}
 
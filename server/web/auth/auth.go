@@ -0,0 +1,234 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"server/logs"
+	"server/settings"
+)
+
+var logger = logs.For("web")
+
+var (
+	accounts gin.Accounts
+	// api token -> user
+	tokens   map[string]string
+	muTokens sync.Mutex
+)
+
+// LoadAuth loads accounts from accs.db and tokens from tokens.db,
+// returns auth middleware or nil if auth is disabled, it is shared by route groups of all listeners.
+// Auth without accounts is error, server must not be left open
+func LoadAuth() (gin.HandlerFunc, error) {
+	if !settings.HttpAuth {
+		return nil, nil
+	}
+	accounts = getAccounts()
+	if len(accounts) == 0 {
+		return nil, errors.New("http auth enabled, but no accounts in " + filepath.Join(settings.Path, "accs.db"))
+	}
+	tokens = getTokens()
+	return BasicAuth(accounts), nil
+}
+
+func getAccounts() gin.Accounts {
+	buf, err := os.ReadFile(filepath.Join(settings.Path, "accs.db"))
+	if err != nil {
+		return nil
+	}
+	var accs gin.Accounts
+	if err = json.Unmarshal(buf, &accs); err != nil {
+		logger.Error("read accs.db", "err", err)
+		return nil
+	}
+	return accs
+}
+
+func getTokens() map[string]string {
+	ret := make(map[string]string)
+	buf, err := os.ReadFile(filepath.Join(settings.Path, "tokens.db"))
+	if err != nil {
+		return ret
+	}
+	if err = json.Unmarshal(buf, &ret); err != nil {
+		logger.Error("read tokens.db", "err", err)
+	}
+	// drop tokens of removed accounts
+	for token, user := range ret {
+		if _, ok := accounts[user]; !ok {
+			delete(ret, token)
+		}
+	}
+	return ret
+}
+
+func saveTokens() error {
+	if settings.ReadOnly {
+		return errors.New("read-only DB mode")
+	}
+	buf, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(settings.Path, "tokens.db"), buf, 0o600)
+}
+
+type authPair struct {
+	value string
+	user  string
+}
+
+func processAccounts(accounts gin.Accounts) []authPair {
+	pairs := make([]authPair, 0, len(accounts))
+	for user, password := range accounts {
+		base := user + ":" + password
+		pairs = append(pairs, authPair{
+			value: "Basic " + base64.StdEncoding.EncodeToString([]byte(base)),
+			user:  user,
+		})
+	}
+	return pairs
+}
+
+func searchCredential(pairs []authPair, authValue string) (string, bool) {
+	if authValue == "" {
+		return "", false
+	}
+	for _, pair := range pairs {
+		if subtle.ConstantTimeCompare([]byte(pair.value), []byte(authValue)) == 1 {
+			return pair.user, true
+		}
+	}
+	return "", false
+}
+
+func searchToken(token string) (string, bool) {
+	if token == "" {
+		return "", false
+	}
+	muTokens.Lock()
+	defer muTokens.Unlock()
+	for t, user := range tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return user, true
+		}
+	}
+	return "", false
+}
+
+// BasicAuth marks request as auth required and sets user by basic credentials,
+// bearer token or token query param. Request is not aborted, handlers decide by CheckAuth
+func BasicAuth(accounts gin.Accounts) gin.HandlerFunc {
+	pairs := processAccounts(accounts)
+	return func(c *gin.Context) {
+		c.Set("auth_required", true)
+
+		authValue := c.Request.Header.Get("Authorization")
+		if user, found := searchCredential(pairs, authValue); found {
+			c.Set(gin.AuthUserKey, user)
+			return
+		}
+		token, isBearer := strings.CutPrefix(authValue, "Bearer ")
+		if !isBearer {
+			token = c.Query("token")
+		}
+		if user, found := searchToken(token); found {
+			c.Set(gin.AuthUserKey, user)
+		}
+	}
+}
+
+// CheckAuth aborts request without authorized user if auth is required
+func CheckAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !c.GetBool("auth_required") {
+			return
+		}
+		if c.GetString(gin.AuthUserKey) != "" {
+			return
+		}
+		c.Header("WWW-Authenticate", "Basic realm=Authorization Required")
+		c.AbortWithStatus(http.StatusUnauthorized)
+	}
+}
+
+// CheckAuthOrLocal aborts request without authorized user if auth is required,
+// without auth only clients of loopback or unix socket are allowed
+func CheckAuthOrLocal() gin.HandlerFunc {
+	check := CheckAuth()
+	return func(c *gin.Context) {
+		if c.GetBool("auth_required") {
+			check(c)
+			return
+		}
+		host, _, err := net.SplitHostPort(c.Request.RemoteAddr)
+		if err != nil {
+			// unix socket has no remote addr
+			if c.Request.RemoteAddr == "" || c.Request.RemoteAddr == "@" {
+				return
+			}
+			host = c.Request.RemoteAddr
+		}
+		if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+			c.AbortWithStatus(http.StatusForbidden)
+		}
+	}
+}
+
+// ListTokens returns api tokens of user
+func ListTokens(user string) []string {
+	muTokens.Lock()
+	defer muTokens.Unlock()
+	ret := []string{}
+	for t, u := range tokens {
+		if u == user {
+			ret = append(ret, t)
+		}
+	}
+	return ret
+}
+
+// AddToken generates new api token for user
+func AddToken(user string) (string, error) {
+	if _, ok := accounts[user]; !ok {
+		return "", errors.New("unknown user")
+	}
+	buf := make([]byte, 20)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+
+	muTokens.Lock()
+	defer muTokens.Unlock()
+	tokens[token] = user
+	if err := saveTokens(); err != nil {
+		delete(tokens, token)
+		return "", err
+	}
+	return token, nil
+}
+
+// RemToken revokes api token of user
+func RemToken(user, token string) error {
+	muTokens.Lock()
+	defer muTokens.Unlock()
+	if u, ok := tokens[token]; !ok || u != user {
+		return errors.New("token not found")
+	}
+	delete(tokens, token)
+	return saveTokens()
+}
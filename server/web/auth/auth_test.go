@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"server/settings"
+)
+
+func setTestAuth(t *testing.T, httpAuth bool, files map[string]string) {
+	t.Helper()
+	oldPath, oldAuth, oldAccounts, oldTokens := settings.Path, settings.HttpAuth, accounts, tokens
+	t.Cleanup(func() { settings.Path, settings.HttpAuth, accounts, tokens = oldPath, oldAuth, oldAccounts, oldTokens })
+	settings.Path = t.TempDir()
+	settings.HttpAuth = httpAuth
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(settings.Path, name), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLoadAuth(t *testing.T) {
+	setTestAuth(t, false, map[string]string{"accs.db": `{"user":"pass"}`})
+	if h, err := LoadAuth(); h != nil || err != nil {
+		t.Errorf("disabled auth: handler %v, err %v", h != nil, err)
+	}
+
+	setTestAuth(t, true, nil)
+	if h, err := LoadAuth(); h != nil || err == nil {
+		t.Errorf("auth without accounts: handler %v, err %v", h != nil, err)
+	}
+
+	setTestAuth(t, true, map[string]string{
+		"accs.db":   `{"user":"pass"}`,
+		"tokens.db": `{"tok1":"user","tok2":"removed"}`,
+	})
+	h, err := LoadAuth()
+	if h == nil || err != nil {
+		t.Fatalf("auth with accounts: handler %v, err %v", h != nil, err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, tc := range []struct {
+		name, header, query, user string
+	}{
+		{"basic", "Basic dXNlcjpwYXNz", "", "user"},
+		{"wrong password", "Basic dXNlcjp4", "", ""},
+		{"bearer", "Bearer tok1", "", "user"},
+		{"query token", "", "tok1", "user"},
+		{"token of removed account", "Bearer tok2", "", ""},
+	} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/stream?token="+tc.query, nil)
+		if tc.header != "" {
+			c.Request.Header.Set("Authorization", tc.header)
+		}
+		h(c)
+		if !c.GetBool("auth_required") || c.GetString(gin.AuthUserKey) != tc.user {
+			t.Errorf("%s: user %q, want %q", tc.name, c.GetString(gin.AuthUserKey), tc.user)
+		}
+	}
+}
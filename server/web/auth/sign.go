@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"server/settings"
+)
+
+var (
+	signKeys *settings.SignKeys
+	muSign   sync.Mutex
+)
+
+func signTTL() time.Duration {
+	return time.Duration(settings.BTsets.SignLinksTTL) * time.Hour
+}
+
+// getSignKeys loads keys from settings and rotates current key after ttl
+func getSignKeys() *settings.SignKeys {
+	muSign.Lock()
+	defer muSign.Unlock()
+	if signKeys == nil {
+		signKeys = settings.GetSignKeys()
+	}
+	now := time.Now()
+	if signKeys != nil && len(signKeys.Current) > 0 && now.Sub(time.Unix(signKeys.Rotated, 0)) < signTTL() {
+		return signKeys
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		logger.Error("generate sign key", "err", err)
+		return signKeys
+	}
+	keys := &settings.SignKeys{Current: key, Rotated: now.Unix()}
+	if signKeys != nil {
+		keys.Previous = signKeys.Current
+	}
+	signKeys = keys
+	settings.SetSignKeys(keys)
+	return signKeys
+}
+
+func linkSign(key []byte, hash, index string, exp int64) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(strings.ToLower(hash) + "/" + index + "/" + strconv.FormatInt(exp, 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// SignLink returns query params exp and sig for link of torrent file, index is empty for whole torrent
+func SignLink(hash, index string) string {
+	keys := getSignKeys()
+	if keys == nil {
+		return ""
+	}
+	exp := time.Now().Add(signTTL()).Unix()
+	return "exp=" + strconv.FormatInt(exp, 10) + "&sig=" + url.QueryEscape(linkSign(keys.Current, hash, index, exp))
+}
+
+// CheckSign checks signature of link is valid and not expired
+func CheckSign(hash, index, exp, sig string) bool {
+	if sig == "" {
+		return false
+	}
+	expTime, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || time.Now().Unix() > expTime {
+		return false
+	}
+	keys := getSignKeys()
+	if keys == nil {
+		return false
+	}
+	for _, key := range [][]byte{keys.Current, keys.Previous} {
+		if len(key) > 0 && hmac.Equal([]byte(linkSign(key, hash, index, expTime)), []byte(sig)) {
+			return true
+		}
+	}
+	return false
+}
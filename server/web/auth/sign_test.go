@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"server/settings"
+)
+
+const testHash = "0123456789ABCDEF0123456789ABCDEF01234567"
+
+func setTestKeys(t *testing.T, keys *settings.SignKeys) {
+	t.Helper()
+	oldSets, oldKeys := settings.BTsets, signKeys
+	t.Cleanup(func() { settings.BTsets, signKeys = oldSets, oldKeys })
+	settings.BTsets = &settings.BTSets{SignLinksTTL: 24}
+	signKeys = keys
+}
+
+func signParams(t *testing.T, hash, index string) (exp, sig string) {
+	t.Helper()
+	q, err := url.ParseQuery(SignLink(hash, index))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return q.Get("exp"), q.Get("sig")
+}
+
+func TestCheckSign(t *testing.T) {
+	setTestKeys(t, &settings.SignKeys{Current: []byte("current key"), Rotated: time.Now().Unix()})
+	exp, sig := signParams(t, testHash, "2")
+
+	past := time.Now().Add(-time.Minute).Unix()
+	expired := linkSign(signKeys.Current, testHash, "2", past)
+
+	for _, tc := range []struct {
+		name                  string
+		hash, index, exp, sig string
+		want                  bool
+	}{
+		{"valid", testHash, "2", exp, sig, true},
+		{"hash case", "0123456789abcdef0123456789abcdef01234567", "2", exp, sig, true},
+		{"other index", testHash, "3", exp, sig, false},
+		{"whole torrent", testHash, "", exp, sig, false},
+		{"other hash", "1123456789abcdef0123456789abcdef01234567", "2", exp, sig, false},
+		{"extended exp", testHash, "2", exp + "0", sig, false},
+		{"expired", testHash, "2", strconv.FormatInt(past, 10), expired, false},
+		{"no sig", testHash, "2", exp, "", false},
+		{"wrong exp", testHash, "2", "soon", sig, false},
+	} {
+		if got := CheckSign(tc.hash, tc.index, tc.exp, tc.sig); got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCheckSignRotation(t *testing.T) {
+	setTestKeys(t, &settings.SignKeys{Current: []byte("old key"), Rotated: time.Now().Unix()})
+	exp, sig := signParams(t, testHash, "")
+
+	signKeys = &settings.SignKeys{Current: []byte("new key"), Previous: []byte("old key"), Rotated: time.Now().Unix()}
+	if !CheckSign(testHash, "", exp, sig) {
+		t.Error("link signed by previous key is refused")
+	}
+
+	signKeys = &settings.SignKeys{Current: []byte("newer key"), Previous: []byte("new key"), Rotated: time.Now().Unix()}
+	if CheckSign(testHash, "", exp, sig) {
+		t.Error("link signed by dropped key is accepted")
+	}
+}
@@ -1,25 +1,37 @@
 package web
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log/slog"
 	"net"
+	"net/http"
 	"os"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-contrib/location"
 	"github.com/gin-gonic/gin"
 
+	"server/dlna"
+	"server/logs"
 	"server/settings"
-
-	"log"
 	"server/torr"
 	"server/version"
 	"server/web/api"
+	"server/web/auth"
 )
 
+var logger = logs.For("web")
+
 var (
-	BTS      = torr.NewBTS()
-	waitChan = make(chan error)
+	BTS       = torr.NewBTS()
+	waitChan  = make(chan error)
+	servers   []*http.Server
+	muServers sync.Mutex
 )
 
 //	@title			Swagger Torrserver API
@@ -35,11 +47,11 @@ var (
 // @externalDocs.description	OpenAPI
 // @externalDocs.url			https://swagger.io/resources/open-api/
 func Start() {
-	log.Println("Start TorrServer " + version.Version + " torrent " + version.GetTorrentVersion())
+	logger.Info("start TorrServer", "version", version.Version, "torrent", version.GetTorrentVersion())
 	err := BTS.Connect()
 	if err != nil {
-		log.Println("BTS.Connect() error!", err) // waitChan <- err
-		os.Exit(1)                              // return
+		logger.Error("BTS.Connect()", "err", err) // waitChan <- err
+		os.Exit(1)                                // return
 	}
 
 	gin.SetMode(gin.ReleaseMode)
@@ -52,13 +64,35 @@ func Start() {
 	corsCfg.AllowAllOrigins = true
 	corsCfg.AllowPrivateNetwork = true
 	corsCfg.AllowHeaders = []string{"Origin", "Content-Length", "Content-Type", "X-Requested-With", "Accept", "Authorization"}
+	corsCfg.ExposeHeaders = []string{"X-Total-Count"}
 
 	route := gin.New()
-	route.Use(gin.Recovery(), cors.New(corsCfg), location.Default())
+	route.Use(gin.Recovery(), requestLogger(), cors.New(corsCfg), location.Default())
 
 	route.GET("/echo", echo)
 
-	api.SetupRoute(route)
+	authHandler, err := auth.LoadAuth()
+	if err != nil {
+		logger.Error("setup auth", "err", err)
+		os.Exit(1)
+	}
+	if authHandler != nil {
+		logger.Info("http auth enabled")
+		api.SetupRoute(route.Group("/", authHandler))
+	} else {
+		api.SetupRoute(route)
+	}
+
+	// lan devices play links of media server on its own listener without api
+	playRoute := gin.New()
+	playRoute.Use(gin.Recovery(), requestLogger())
+	if authHandler != nil {
+		api.SetupPlayRoute(playRoute.Group("/", authHandler))
+	} else {
+		api.SetupPlayRoute(playRoute)
+	}
+	dlna.SetPlayHandler(playRoute.Handler())
+	dlna.Apply()
 
 	go func() {
 		var l net.Listener
@@ -68,20 +102,99 @@ func Start() {
 			l, err = net.Listen("tcp", settings.LAddr)
 		}
 		if err != nil {
-			log.Fatalln("Failed to bind on", settings.LAddr)
+			logger.Error("failed to bind", "addr", settings.LAddr, "err", err)
+			os.Exit(1)
 		}
-		log.Println("Start http server at", settings.LAddr)
-		waitChan <- route.RunListener(l)
+		logger.Info("start http server", "addr", settings.LAddr)
+		serve(&http.Server{Handler: route.Handler()}, l, false)
 	}()
+
+	if settings.Ssl {
+		go func() {
+			cs, err := newCertStore()
+			if err != nil {
+				logger.Error("failed to load certificate", "err", err)
+				os.Exit(1)
+			}
+			l, err := net.Listen("tcp", settings.SslAddr)
+			if err != nil {
+				logger.Error("failed to bind", "addr", settings.SslAddr, "err", err)
+				os.Exit(1)
+			}
+			srv := &http.Server{
+				Handler:   route.Handler(),
+				TLSConfig: &tls.Config{GetCertificate: cs.GetCertificate},
+			}
+			logger.Info("start https server", "addr", settings.SslAddr)
+			serve(srv, l, true)
+		}()
+	}
+}
+
+func serve(srv *http.Server, l net.Listener, isTLS bool) {
+	muServers.Lock()
+	servers = append(servers, srv)
+	muServers.Unlock()
+	var err error
+	if isTLS {
+		err = srv.ServeTLS(l, "", "")
+	} else {
+		err = srv.Serve(l)
+	}
+	if !errors.Is(err, http.ErrServerClosed) {
+		waitChan <- err
+	}
 }
 
 func Wait() error {
 	return <-waitChan
 }
 
-func Stop() {
+// Stop stops accepting connections and waits for active requests and streams until ctx is done,
+// connections left are closed, then torrent client is disconnected
+func Stop(ctx context.Context) {
+	// ends long-lived event streams
+	torr.Shutdown()
+	dlna.Stop()
+
+	muServers.Lock()
+	list := servers
+	muServers.Unlock()
+
+	var wg sync.WaitGroup
+	for _, srv := range list {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := srv.Shutdown(ctx); err != nil {
+				logger.Warn("close active connections", "err", err)
+				srv.Close()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// streams of closed connections end on write error
+	waitCtx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := torr.WaitStreams(waitCtx); err != nil {
+		logger.Warn("wait streams", "err", err)
+	}
 	BTS.Disconnect()
-	waitChan <- nil
+}
+
+// requestLogger logs handled requests on debug level
+func requestLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !logger.Enabled(c, slog.LevelDebug) {
+			c.Next()
+			return
+		}
+		start := time.Now()
+		c.Next()
+		logger.Debug("request", "method", c.Request.Method, "path", c.Request.URL.Path, "status", c.Writer.Status(),
+			"remote", c.Request.RemoteAddr, "duration", time.Since(start))
+	}
 }
 
 // echo godoc
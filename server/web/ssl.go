@@ -0,0 +1,132 @@
+package web
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"server/settings"
+)
+
+type certStore struct {
+	certPath string
+	keyPath  string
+	cert     *tls.Certificate
+	mu       sync.RWMutex
+}
+
+// newCertStore loads cert pair, self-signed pair is generated if files not exist.
+// Pair is reloaded on SIGHUP
+func newCertStore() (*certStore, error) {
+	cs := &certStore{
+		certPath: settings.SslCert,
+		keyPath:  settings.SslKey,
+	}
+	if cs.certPath == "" {
+		cs.certPath = filepath.Join(settings.Path, "server.crt")
+	}
+	if cs.keyPath == "" {
+		cs.keyPath = filepath.Join(settings.Path, "server.key")
+	}
+
+	_, errCert := os.Stat(cs.certPath)
+	_, errKey := os.Stat(cs.keyPath)
+	if errors.Is(errCert, os.ErrNotExist) && errors.Is(errKey, os.ErrNotExist) {
+		logger.Info("generate self-signed certificate", "path", cs.certPath)
+		if err := generateSelfSigned(cs.certPath, cs.keyPath); err != nil {
+			return nil, err
+		}
+	}
+	if err := cs.load(); err != nil {
+		return nil, err
+	}
+
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGHUP)
+		for range sig {
+			if err := cs.load(); err != nil {
+				logger.Error("reload certificate", "err", err)
+			} else {
+				logger.Info("certificate reloaded", "path", cs.certPath)
+			}
+		}
+	}()
+	return cs, nil
+}
+
+func (cs *certStore) load() error {
+	cert, err := tls.LoadX509KeyPair(cs.certPath, cs.keyPath)
+	if err != nil {
+		return err
+	}
+	cs.mu.Lock()
+	cs.cert = &cert
+	cs.mu.Unlock()
+	return nil
+}
+
+func (cs *certStore) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return cs.cert, nil
+}
+
+func generateSelfSigned(certPath, keyPath string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"TorrServer"}, CommonName: "TorrServer"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+	}
+	if host, err := os.Hostname(); err == nil && host != "localhost" {
+		tmpl.DNSNames = append(tmpl.DNSNames, host)
+	}
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok {
+				tmpl.IPAddresses = append(tmpl.IPAddresses, ipNet.IP)
+			}
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	if err = os.WriteFile(keyPath, keyPem, 0o600); err != nil {
+		return err
+	}
+	return os.WriteFile(certPath, certPem, 0o644)
+}